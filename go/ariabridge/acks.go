@@ -0,0 +1,153 @@
+package ariabridge
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+var ErrAckTimeout = errors.New("ack timeout")
+
+type pendingAck struct {
+	q        queued
+	attempts int
+	timer    *time.Timer
+}
+
+func (c *Client) OnSendResult(handler func(ev map[string]any, err error)) {
+	c.mu.Lock()
+	c.sendResult = handler
+	c.mu.Unlock()
+}
+
+// binaryAcks reports whether acks may arrive as binary frames. Unlike other
+// features it is off unless the server advertised it explicitly.
+func (c *Client) binaryAcks() bool {
+	if !c.cfg.BinaryAcks {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.features[FeatureBinaryAcks]
+}
+
+// binaryAck decodes a binary ack frame: the acked seq as 8 bytes big-endian.
+func binaryAck(data []byte) (int64, bool) {
+	if len(data) != 8 {
+		return 0, false
+	}
+	return int64(binary.BigEndian.Uint64(data)), true
+}
+
+func (c *Client) acksEnabled() bool {
+	return c.cfg.AckTimeout > 0 && c.featureEnabled(FeatureAcks)
+}
+
+// stampSeq assigns the next sequence number to events that will be acked,
+// either for AckTimeout retries or FlowControl credit accounting, or chained
+// with ChainSequence. Raw events are sent verbatim and are never tracked.
+func (c *Client) stampSeq(q *queued) {
+	if (!c.acksEnabled() && !c.flowControl() && !c.cfg.ChainSequence) || q.raw != nil || q.seq != 0 {
+		return
+	}
+	c.mu.Lock()
+	c.seq++
+	q.seq = c.seq
+	c.mu.Unlock()
+	q.ev["seq"] = q.seq
+}
+
+// chainSeq sets prevSeq to the seq of the event written before q on this
+// connection, 0 for the first, so the server can spot gaps and reordering
+// without acks. Each connection starts a new chain. The returned func makes
+// q the next link and must only be called once q has been written.
+func (c *Client) chainSeq(q queued) (written func()) {
+	if !c.cfg.ChainSequence || q.seq == 0 {
+		return func() {}
+	}
+	c.writeMu.Lock()
+	conn, prev := c.conn, c.prevSeq
+	c.writeMu.Unlock()
+	q.ev["prevSeq"] = prev
+	return func() {
+		c.writeMu.Lock()
+		if c.conn == conn {
+			c.prevSeq = q.seq
+		}
+		c.writeMu.Unlock()
+	}
+}
+
+// trackAck arms the ack timer for an event that was just written. A resent
+// event keeps its attempt count so it is only retried once.
+func (c *Client) trackAck(q queued) {
+	if q.seq == 0 || !c.acksEnabled() {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.pending[q.seq]
+	if !ok {
+		p = &pendingAck{q: q}
+		c.pending[q.seq] = p
+	}
+	p.attempts++
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	seq := q.seq
+	p.timer = time.AfterFunc(c.cfg.AckTimeout, func() { c.ackExpired(seq) })
+}
+
+func (c *Client) ackExpired(seq int64) {
+	c.mu.Lock()
+	p, ok := c.pending[seq]
+	if !ok {
+		c.mu.Unlock()
+		return
+	}
+	if p.attempts < 2 {
+		c.mu.Unlock()
+		// the event was accepted once already; resend it as is
+		c.bufMu.Lock()
+		_ = c.pushLocked(p.q)
+		c.unlockBuf()
+		return
+	}
+	delete(c.pending, seq)
+	handler := c.sendResult
+	c.mu.Unlock()
+	if handler != nil {
+		handler(p.q.ev, ErrAckTimeout)
+	}
+}
+
+// stopAcks stops every ack timer once the client shuts down, so none fires
+// into it later, and reports the events still waiting as ErrClosed.
+func (c *Client) stopAcks() {
+	c.mu.Lock()
+	pending := c.pending
+	c.pending = map[int64]*pendingAck{}
+	handler := c.sendResult
+	c.mu.Unlock()
+	for _, p := range pending {
+		p.timer.Stop()
+		if handler != nil {
+			handler(p.q.ev, ErrClosed)
+		}
+	}
+}
+
+func (c *Client) resolveAck(seq int64) {
+	c.mu.Lock()
+	p, ok := c.pending[seq]
+	if ok {
+		p.timer.Stop()
+		delete(c.pending, seq)
+	}
+	handler := c.sendResult
+	c.mu.Unlock()
+	if ok && handler != nil {
+		handler(p.q.ev, nil)
+	}
+}
@@ -0,0 +1,231 @@
+package ariabridge
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type sendResults struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+func (r *sendResults) record(_ map[string]any, err error) {
+	r.mu.Lock()
+	r.errs = append(r.errs, err)
+	r.mu.Unlock()
+}
+
+func (r *sendResults) snapshot() []error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]error(nil), r.errs...)
+}
+
+func TestAckTimeoutResendsOnce(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	var mu sync.Mutex
+	seen := map[float64]int{}
+	h.onMessage(func(conn *websocket.Conn, m map[string]any) {
+		seq, ok := m["seq"].(float64)
+		if !ok {
+			return
+		}
+		mu.Lock()
+		seen[seq]++
+		first := seen[seq] == 1
+		mu.Unlock()
+		if !first {
+			_ = conn.WriteJSON(map[string]any{"type": "ack", "seq": seq})
+		}
+	})
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", AckTimeout: 50 * time.Millisecond})
+	results := &sendResults{}
+	c.OnSendResult(results.record)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+
+	waitFor(t, func() bool { return len(h.ofType("hello")) == 1 }, time.Second)
+	_ = c.SendConsole("info", "needs-ack")
+
+	waitFor(t, func() bool { return len(results.snapshot()) == 1 }, time.Second)
+	if err := results.snapshot()[0]; err != nil {
+		t.Fatalf("send result %v", err)
+	}
+	if n := len(h.ofType("console")); n != 2 {
+		t.Fatalf("console sent %d times", n)
+	}
+}
+
+func TestAckTimeoutGivesUpAfterRetry(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", AckTimeout: 30 * time.Millisecond})
+	results := &sendResults{}
+	c.OnSendResult(results.record)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+
+	waitFor(t, func() bool { return len(h.ofType("hello")) == 1 }, time.Second)
+	_ = c.SendConsole("info", "never-acked")
+
+	waitFor(t, func() bool { return len(results.snapshot()) == 1 }, time.Second)
+	if err := results.snapshot()[0]; !errors.Is(err, ErrAckTimeout) {
+		t.Fatalf("send result %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if n := len(h.ofType("console")); n != 2 {
+		t.Fatalf("console sent %d times", n)
+	}
+}
+
+func TestAckResendIsNotTeedAgain(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	path := filepath.Join(t.TempDir(), "bridge.jsonl")
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", AckTimeout: 30 * time.Millisecond, TeeFile: path})
+	results := &sendResults{}
+	c.OnSendResult(results.record)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+	_ = c.SendConsole("info", "never-acked")
+	waitFor(t, func() bool { return len(results.snapshot()) == 1 }, time.Second)
+	if n := len(h.ofType("console")); n != 2 {
+		t.Fatalf("console sent %d times", n)
+	}
+	_ = c.Close()
+	if n := len(teeLines(t, path)); n != 1 {
+		t.Fatalf("tee has %d lines", n)
+	}
+}
+
+func TestCloseStopsAckTimers(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", AckTimeout: 30 * time.Millisecond})
+	results := &sendResults{}
+	c.OnSendResult(results.record)
+	go c.Start(context.Background())
+
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+	_ = c.SendConsole("info", "in flight")
+	waitFor(t, func() bool { return len(h.ofType("console")) == 1 }, time.Second)
+	_ = c.Close()
+	time.Sleep(100 * time.Millisecond)
+
+	if got := results.snapshot(); len(got) != 1 || !errors.Is(got[0], ErrClosed) {
+		t.Fatalf("send results %v", got)
+	}
+	c.bufMu.Lock()
+	buffered := c.buffer.len()
+	c.bufMu.Unlock()
+	if buffered != 0 || len(h.ofType("console")) != 1 {
+		t.Fatalf("timer resent after close: %d buffered", buffered)
+	}
+}
+
+func TestBinaryAcksResolveBySeq(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	h.authInfo = map[string]any{"features": []string{FeatureAcks, FeatureBinaryAcks}}
+	h.onMessage(func(conn *websocket.Conn, m map[string]any) {
+		seq, ok := m["seq"].(float64)
+		if !ok || m["message"] == "unacked" {
+			return
+		}
+		frame := make([]byte, 8)
+		binary.BigEndian.PutUint64(frame, uint64(seq))
+		_ = conn.WriteMessage(websocket.BinaryMessage, frame)
+	})
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", AckTimeout: 50 * time.Millisecond, BinaryAcks: true})
+	results := &sendResults{}
+	c.OnSendResult(results.record)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+
+	waitFor(t, func() bool { return len(h.ofType("hello")) == 1 }, time.Second)
+	if f, _ := h.ofType("hello")[0]["features"].([]any); len(f) != 1 || f[0] != FeatureBinaryAcks {
+		t.Fatalf("hello features %v", f)
+	}
+	_ = c.SendConsole("info", "acked")
+	_ = c.SendConsole("info", "unacked")
+
+	waitFor(t, func() bool { return len(results.snapshot()) == 2 }, time.Second)
+	got := results.snapshot()
+	if got[0] != nil || !errors.Is(got[1], ErrAckTimeout) {
+		t.Fatalf("send results %v", got)
+	}
+	if n := len(h.ofType("console")); n != 3 {
+		t.Fatalf("console sent %d times", n)
+	}
+	if c.Stats().MalformedMessages != 0 {
+		t.Fatalf("binary ack counted as malformed")
+	}
+}
+
+func TestChainSequenceLinksEventsPerConnection(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", ChainSequence: true, BackoffInitial: 10 * time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	for i := 0; i < 3; i++ {
+		_ = c.SendConsole("info", "m"+itoa(i))
+	}
+	waitFor(t, func() bool { return len(h.ofType("console")) == 3 }, time.Second)
+	prev := 0.0
+	for _, m := range h.ofType("console") {
+		if m["prevSeq"] != prev {
+			t.Fatalf("%v: prevSeq %v, want %v", m["message"], m["prevSeq"], prev)
+		}
+		prev = m["seq"].(float64)
+	}
+
+	h.mu.Lock()
+	_ = h.conn.Close()
+	h.mu.Unlock()
+	waitFor(t, func() bool { return len(h.ofType("hello")) == 2 }, 2*time.Second)
+	_ = c.SendConsole("info", "after reconnect")
+	waitFor(t, func() bool { return len(h.ofType("console")) == 4 }, time.Second)
+	m := h.ofType("console")[3]
+	if m["prevSeq"] != 0.0 || m["seq"].(float64) <= prev {
+		t.Fatalf("first event of new connection %v", m)
+	}
+}
+
+func TestChainSequenceSkipsFailedWrites(t *testing.T) {
+	c := NewClient(ClientConfig{URL: "ws://0.0.0.0:1", ChainSequence: true})
+	q := queued{ev: map[string]any{"type": "console", "message": "lost"}, seq: 7}
+	if err := c.writeQueued(q); !errors.Is(err, errNotConnected) {
+		t.Fatalf("write without a connection returned %v", err)
+	}
+	c.writeMu.Lock()
+	prev := c.prevSeq
+	c.writeMu.Unlock()
+	if prev != 0 {
+		t.Fatalf("failed write advanced the chain to %d", prev)
+	}
+}
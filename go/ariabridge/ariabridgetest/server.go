@@ -0,0 +1,201 @@
+// Package ariabridgetest provides an in-memory bridge host for testing code
+// that uses the ariabridge client.
+package ariabridgetest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+var ErrNoConnection = errors.New("ariabridgetest: no client connected")
+
+// Server speaks the minimal bridge protocol: it answers auth, acknowledges
+// hello, replies to pings, acks sequenced events and routes control results
+// back to Control callers. Every frame the client sends is captured.
+type Server struct {
+	URL string
+
+	srv     *httptest.Server
+	mu      sync.Mutex
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+	msgs    []map[string]any
+	changed chan struct{}
+	waiting map[string]chan map[string]any
+	nextID  int
+}
+
+// NewServer starts a Server on a loopback port. Callers must Close it.
+func NewServer() *Server {
+	s := &Server{changed: make(chan struct{}), waiting: map[string]chan map[string]any{}}
+	up := websocket.Upgrader{}
+	s.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := up.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conn = conn
+		s.mu.Unlock()
+		go s.serve(conn)
+	}))
+	s.URL = "ws" + strings.TrimPrefix(s.srv.URL, "http")
+	return s
+}
+
+// Close disconnects the client and shuts the server down.
+func (s *Server) Close() {
+	s.mu.Lock()
+	conn := s.conn
+	s.mu.Unlock()
+	if conn != nil {
+		_ = conn.Close()
+	}
+	s.srv.Close()
+}
+
+func (s *Server) serve(conn *websocket.Conn) {
+	defer conn.Close()
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			s.mu.Lock()
+			if s.conn == conn {
+				s.conn = nil
+			}
+			s.mu.Unlock()
+			return
+		}
+		var m map[string]any
+		if json.Unmarshal(data, &m) != nil {
+			continue
+		}
+		s.record(m)
+		switch m["type"] {
+		case "auth":
+			_ = s.write(conn, map[string]any{"type": "auth_success", "role": "bridge"})
+		case "hello":
+			_ = s.write(conn, map[string]any{"type": "hello_ack"})
+		case "ping":
+			_ = s.write(conn, map[string]any{"type": "pong"})
+		case "control_result":
+			id := fmt.Sprint(m["id"])
+			s.mu.Lock()
+			ch, ok := s.waiting[id]
+			delete(s.waiting, id)
+			s.mu.Unlock()
+			if ok {
+				ch <- m
+			}
+		}
+		if seq, ok := m["seq"]; ok {
+			_ = s.write(conn, map[string]any{"type": "ack", "seq": seq})
+		}
+	}
+}
+
+func (s *Server) record(m map[string]any) {
+	s.mu.Lock()
+	s.msgs = append(s.msgs, m)
+	close(s.changed)
+	s.changed = make(chan struct{})
+	s.mu.Unlock()
+}
+
+func (s *Server) write(conn *websocket.Conn, v any) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return conn.WriteJSON(v)
+}
+
+// Messages returns every frame received so far, in order.
+func (s *Server) Messages() []map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]map[string]any(nil), s.msgs...)
+}
+
+// MessagesOfType returns the received frames whose type is typ.
+func (s *Server) MessagesOfType(typ string) []map[string]any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []map[string]any
+	for _, m := range s.msgs {
+		if m["type"] == typ {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// WaitFor blocks until at least n frames of type typ have arrived and
+// returns them, or fails when ctx is done.
+func (s *Server) WaitFor(ctx context.Context, typ string, n int) ([]map[string]any, error) {
+	for {
+		s.mu.Lock()
+		changed := s.changed
+		s.mu.Unlock()
+		if got := s.MessagesOfType(typ); len(got) >= n {
+			return got, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Send writes v to the connected client as a server message.
+func (s *Server) Send(v any) error {
+	s.mu.Lock()
+	conn := s.conn
+	s.mu.Unlock()
+	if conn == nil {
+		return ErrNoConnection
+	}
+	return s.write(conn, v)
+}
+
+// Control sends a control_request and waits for the matching control_result.
+func (s *Server) Control(ctx context.Context, action string, args map[string]any) (map[string]any, error) {
+	s.mu.Lock()
+	s.nextID++
+	id := fmt.Sprintf("ctl-%d", s.nextID)
+	ch := make(chan map[string]any, 1)
+	s.waiting[id] = ch
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.waiting, id)
+		s.mu.Unlock()
+	}()
+
+	req := map[string]any{"type": "control_request", "id": id, "action": action}
+	if args != nil {
+		req["args"] = args
+	}
+	if err := s.Send(req); err != nil {
+		return nil, err
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		return res, nil
+	}
+}
+
+// WaitConnected blocks until a client has completed auth and hello.
+func (s *Server) WaitConnected(ctx context.Context) error {
+	_, err := s.WaitFor(ctx, "hello", 1)
+	return err
+}
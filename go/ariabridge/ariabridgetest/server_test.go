@@ -0,0 +1,139 @@
+package ariabridgetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shaneholloman/aria-bridge/go/ariabridge"
+)
+
+func startClient(t *testing.T, s *Server, cfg ariabridge.ClientConfig) (*ariabridge.Client, context.Context) {
+	t.Helper()
+	cfg.URL = s.URL
+	cfg.Secret = "dev-secret"
+	c := ariabridge.NewClient(cfg)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	go c.Start(ctx)
+	if err := s.WaitConnected(ctx); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	return c, ctx
+}
+
+func TestServerHandshakeAndCapture(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	c, ctx := startClient(t, s, ariabridge.ClientConfig{})
+
+	if err := c.SendConsole("info", "hello world"); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	got, err := s.WaitFor(ctx, "console", 1)
+	if err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	if got[0]["message"] != "hello world" {
+		t.Fatalf("console %v", got[0])
+	}
+	msgs := s.Messages()
+	if msgs[0]["type"] != "auth" || msgs[1]["type"] != "hello" {
+		t.Fatalf("handshake order %v", msgs[:2])
+	}
+}
+
+func TestServerPingPong(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	c, ctx := startClient(t, s, ariabridge.ClientConfig{HeartbeatInterval: 20 * time.Millisecond, HeartbeatTimeout: 200 * time.Millisecond})
+
+	if _, err := s.WaitFor(ctx, "ping", 3); err != nil {
+		t.Fatalf("pings: %v", err)
+	}
+	if c.State() != ariabridge.StateConnected {
+		t.Fatalf("state %s", c.State())
+	}
+}
+
+func TestServerControlRoundTrip(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	c := ariabridge.NewClient(ariabridge.ClientConfig{URL: s.URL, Secret: "dev-secret"})
+	c.OnControl(func(m map[string]any) (any, error) {
+		if m["action"] == "fail" {
+			return nil, errors.New("nope")
+		}
+		return map[string]any{"echo": m["args"]}, nil
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go c.Start(ctx)
+	if err := s.WaitConnected(ctx); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+
+	res, err := s.Control(ctx, "echo", map[string]any{"n": 1})
+	if err != nil {
+		t.Fatalf("control: %v", err)
+	}
+	if res["ok"] != true || res["result"].(map[string]any)["echo"].(map[string]any)["n"] != float64(1) {
+		t.Fatalf("result %v", res)
+	}
+	res, err = s.Control(ctx, "fail", nil)
+	if err != nil {
+		t.Fatalf("control: %v", err)
+	}
+	if res["ok"] != false {
+		t.Fatalf("expected failure, got %v", res)
+	}
+}
+
+func TestServerAcksSequencedEvents(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	c, ctx := startClient(t, s, ariabridge.ClientConfig{AckTimeout: time.Second})
+	results := make(chan error, 1)
+	c.OnSendResult(func(_ map[string]any, err error) { results <- err })
+
+	_ = c.SendConsole("info", "acked")
+	select {
+	case err := <-results:
+		if err != nil {
+			t.Fatalf("send result: %v", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("no ack")
+	}
+}
+
+func TestServerInjectsMessages(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	c := ariabridge.NewClient(ariabridge.ClientConfig{URL: s.URL, Secret: "dev-secret"})
+	got := make(chan map[string]any, 4)
+	c.OnServerMessage(func(m map[string]any) {
+		if m["type"] == "notice" {
+			got <- m
+		}
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go c.Start(ctx)
+	if err := s.WaitConnected(ctx); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+
+	if err := s.Send(map[string]any{"type": "notice", "text": "hi"}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	select {
+	case m := <-got:
+		if m["text"] != "hi" {
+			t.Fatalf("message %v", m)
+		}
+	case <-ctx.Done():
+		t.Fatal("message not delivered")
+	}
+}
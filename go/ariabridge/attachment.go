@@ -0,0 +1,68 @@
+package ariabridge
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const attachmentChunkDefault = 64 << 10
+
+// SendAttachment sends a metadata event of type eventType followed by data as
+// binary frames. Each frame starts with a JSON header line naming the event
+// id, chunk index and whether it is the last chunk. The "attachments"
+// capability must be advertised and the client must be connected; a client
+// suspended by IdleTimeout starts reconnecting and the call can be retried. A
+// blob only waits in the buffer, with its metadata, while credits or earlier
+// events hold the metadata back.
+func (c *Client) SendAttachment(eventType string, meta map[string]any, data []byte) error {
+	if err := c.checkWritable(nil); err != nil {
+		return err
+	}
+	if !c.hasCapability("attachments") {
+		return fmt.Errorf("%w: attachments", ErrCapability)
+	}
+	c.bufMu.Lock()
+	alive := c.connAlive()
+	c.bufMu.Unlock()
+	if !alive {
+		c.wakeIdle()
+		return fmt.Errorf("attachment: %w", errNotConnected)
+	}
+	size := c.cfg.AttachmentChunkSize
+	chunks := (len(data) + size - 1) / size
+	if chunks == 0 {
+		chunks = 1
+	}
+	ev := copyEvent(meta)
+	ev["type"] = eventType
+	ev["timestamp"] = time.Now().UnixMilli()
+	c.stampFields(ev)
+	q := queued{ev: ev}
+	c.stampEventID(q)
+	id := ev["eventId"]
+	ev["attachment"] = map[string]any{"id": id, "size": len(data), "chunks": chunks}
+	for i := 0; i < chunks; i++ {
+		end := (i + 1) * size
+		if end > len(data) {
+			end = len(data)
+		}
+		header, err := json.Marshal(map[string]any{"attachment": id, "seq": i, "last": i == chunks-1})
+		if err != nil {
+			return err
+		}
+		q.frames = append(q.frames, append(append(header, '\n'), data[i*size:end]...))
+	}
+	return c.push(q)
+}
+
+func (c *Client) writeBinary(data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.conn == nil {
+		return errNotConnected
+	}
+	return c.conn.WriteMessage(websocket.BinaryMessage, data)
+}
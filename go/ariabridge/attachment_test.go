@@ -0,0 +1,92 @@
+package ariabridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSendAttachmentChunksAndReassembles(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", Capabilities: []string{"console", "error", "attachments"}, AttachmentChunkSize: 100})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	blob := bytes.Repeat([]byte{0, 1, 2, 0xff, '\n'}, 50)
+	if err := c.SendAttachment("error", map[string]any{"message": "crash", "name": "heap.bin"}, blob); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	waitFor(t, func() bool { h.mu.Lock(); defer h.mu.Unlock(); return len(h.raw) >= 2+1+3 }, time.Second)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	meta := h.msgs[2]
+	att := meta["attachment"].(map[string]any)
+	if meta["type"] != "error" || meta["name"] != "heap.bin" || att["id"] != meta["eventId"] || att["chunks"] != float64(3) {
+		t.Fatalf("metadata %v", meta)
+	}
+	var got []byte
+	for i, frame := range h.raw[3:] {
+		nl := bytes.IndexByte(frame, '\n')
+		var hdr struct {
+			Attachment string
+			Seq        int
+			Last       bool
+		}
+		if err := json.Unmarshal(frame[:nl], &hdr); err != nil {
+			t.Fatalf("chunk header %q: %v", frame[:nl], err)
+		}
+		if hdr.Attachment != meta["eventId"] || hdr.Seq != i || hdr.Last != (i == 2) {
+			t.Fatalf("chunk %d header %+v", i, hdr)
+		}
+		got = append(got, frame[nl+1:]...)
+	}
+	if !bytes.Equal(got, blob) {
+		t.Fatalf("reassembled %d bytes, want %d", len(got), len(blob))
+	}
+}
+
+func TestSendAttachmentRequiresCapability(t *testing.T) {
+	c := NewClient(ClientConfig{URL: "ws://127.0.0.1:1"})
+	if err := c.SendAttachment("error", nil, []byte("x")); !errors.Is(err, ErrCapability) {
+		t.Fatalf("expected ErrCapability, got %v", err)
+	}
+}
+
+func TestSendAttachmentThroughWriterAndCredits(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", Capabilities: []string{"console", "attachments"}, WriteQueueSize: 4, FlowControl: true})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	if err := c.SendAttachment("console", map[string]any{"message": "dump"}, []byte("blob")); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if n := len(h.ofType("console")); n != 0 {
+		t.Fatalf("metadata sent without credits")
+	}
+
+	h.sendJSON(t, map[string]any{"type": "credit", "n": 1})
+	waitFor(t, func() bool { h.mu.Lock(); defer h.mu.Unlock(); return len(h.raw) >= 4 }, time.Second)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	meta := h.msgs[2]
+	if meta["type"] != "console" || meta["seq"] == nil || meta["order"] == nil {
+		t.Fatalf("metadata %v", meta)
+	}
+	if frame := h.raw[3]; !bytes.HasSuffix(frame, []byte("\nblob")) {
+		t.Fatalf("frame after metadata %q", frame)
+	}
+}
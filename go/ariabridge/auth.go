@@ -0,0 +1,47 @@
+package ariabridge
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Auth modes. In AuthHMAC mode the secret never leaves the process: auth
+// carries an HMAC-SHA256 of nonce+timestamp keyed by the secret instead.
+const (
+	AuthPlain = "plain"
+	AuthHMAC  = "hmac"
+)
+
+func (c *Client) authMessage() (AuthMessage, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return AuthMessage{}, err
+	}
+	msg := AuthMessage{Type: "auth", Role: "bridge", Nonce: hex.EncodeToString(nonce), Timestamp: time.Now().UnixMilli()}
+	if c.cfg.AuthMode == AuthHMAC {
+		msg.Signature = AuthSignature(c.secret(), msg.Nonce, msg.Timestamp)
+	} else {
+		msg.Secret = c.secret()
+	}
+	return msg, nil
+}
+
+// AuthSignature is the hex HMAC-SHA256 of nonce followed by the decimal
+// timestamp, keyed by secret. Servers use it to verify AuthHMAC auth.
+func AuthSignature(secret, nonce string, timestamp int64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(nonce + strconv.FormatInt(timestamp, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (c *Client) handshakeHeader() http.Header {
+	if c.cfg.AuthMode == AuthHMAC {
+		return nil
+	}
+	return http.Header{c.cfg.SecretHeaderName: []string{c.secret()}}
+}
@@ -0,0 +1,198 @@
+package ariabridge
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// silentServer accepts websocket connections and reads frames but never
+// answers auth.
+func silentServer(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	var conns atomic.Int32
+	up := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := up.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conns.Add(1)
+		go func() {
+			defer conn.Close()
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+	}))
+	return srv, &conns
+}
+
+func TestMaxAuthTimeoutsGivesUp(t *testing.T) {
+	srv, conns := silentServer(t)
+	defer srv.Close()
+
+	c := NewClient(ClientConfig{
+		URL:              "ws" + srv.URL[4:],
+		Secret:           "dev-secret",
+		HeartbeatTimeout: 30 * time.Millisecond,
+		BackoffInitial:   5 * time.Millisecond,
+		BackoffMax:       10 * time.Millisecond,
+		MaxAuthTimeouts:  3,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	err := c.Start(ctx)
+	if !errors.Is(err, ErrAuthTimeout) {
+		t.Fatalf("expected ErrAuthTimeout, got %v", err)
+	}
+	if n := conns.Load(); n != 3 {
+		t.Fatalf("connected %d times", n)
+	}
+}
+
+func TestAuthTimeoutTerminalByDefault(t *testing.T) {
+	srv, conns := silentServer(t)
+	defer srv.Close()
+
+	c := NewClient(ClientConfig{URL: "ws" + srv.URL[4:], Secret: "dev-secret", HeartbeatTimeout: 30 * time.Millisecond})
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := c.Start(ctx); !errors.Is(err, ErrAuthTimeout) {
+		t.Fatalf("expected ErrAuthTimeout, got %v", err)
+	}
+	if n := conns.Load(); n != 1 {
+		t.Fatalf("connected %d times", n)
+	}
+}
+
+func TestHMACAuthMessage(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", AuthMode: AuthHMAC})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	auth := h.ofType("auth")[0]
+	if _, ok := auth["secret"]; ok {
+		t.Fatalf("hmac auth leaked the secret: %v", auth)
+	}
+	nonce, _ := auth["nonce"].(string)
+	ts, _ := auth["timestamp"].(float64)
+	if len(nonce) != 32 || ts == 0 {
+		t.Fatalf("auth nonce/timestamp %v", auth)
+	}
+	mac := hmac.New(sha256.New, []byte("dev-secret"))
+	mac.Write([]byte(nonce + strconv.FormatInt(int64(ts), 10)))
+	if auth["signature"] != hex.EncodeToString(mac.Sum(nil)) {
+		t.Fatalf("signature does not verify: %v", auth["signature"])
+	}
+	h.mu.Lock()
+	header := h.header.Get("X-Bridge-Secret")
+	h.mu.Unlock()
+	if header != "" {
+		t.Fatalf("hmac mode sent the secret header")
+	}
+}
+
+func TestPlainAuthNoncePerConnection(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+	c.UpdateSecret("dev-secret")
+	waitFor(t, func() bool { return len(h.ofType("auth")) == 2 }, time.Second)
+
+	auths := h.ofType("auth")
+	if auths[0]["secret"] != "dev-secret" || auths[0]["nonce"] == auths[1]["nonce"] {
+		t.Fatalf("auths %v", auths)
+	}
+}
+
+func TestPostAuthDelayBeforeHello(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	var authAt time.Time
+	hello := make(chan time.Duration, 1)
+	h.onMessage(func(_ *websocket.Conn, m map[string]any) {
+		switch m["type"] {
+		case "auth":
+			authAt = time.Now()
+		case "hello":
+			hello <- time.Since(authAt)
+		}
+	})
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", PostAuthDelay: 80 * time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	select {
+	case gap := <-hello:
+		if gap < 80*time.Millisecond {
+			t.Fatalf("hello %v after auth, want at least 80ms", gap)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no hello")
+	}
+}
+
+func TestConnectTimeoutAbandonsSlowAuthAndRetries(t *testing.T) {
+	srv, conns := silentServer(t)
+	defer srv.Close()
+
+	var logMu sync.Mutex
+	var logs []string
+	c := NewClient(ClientConfig{
+		URL:              "ws" + srv.URL[4:],
+		Secret:           "dev-secret",
+		HeartbeatTimeout: 10 * time.Second,
+		ConnectTimeout:   50 * time.Millisecond,
+		BackoffInitial:   5 * time.Millisecond,
+		BackoffMax:       10 * time.Millisecond,
+		Logger: func(msg string) {
+			logMu.Lock()
+			logs = append(logs, msg)
+			logMu.Unlock()
+		},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- c.Start(ctx) }()
+
+	waitFor(t, func() bool { return conns.Load() >= 3 }, time.Second)
+	select {
+	case err := <-done:
+		t.Fatalf("start gave up: %v", err)
+	default:
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("start returned %v", err)
+	}
+	logMu.Lock()
+	defer logMu.Unlock()
+	if len(logs) == 0 || !strings.Contains(logs[0], ErrConnectTimeout.Error()) {
+		t.Fatalf("logs %v", logs)
+	}
+}
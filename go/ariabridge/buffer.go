@@ -0,0 +1,109 @@
+package ariabridge
+
+import "time"
+
+// eventBuffer holds events while disconnected. Levels listed in
+// LevelBufferLimits get their own ring so a flood at one level cannot evict
+// another; everything else shares the main ring.
+type eventBuffer struct {
+	main   *ring
+	levels map[string]*ring
+	next   uint64
+}
+
+func newEventBuffer(limit int, levelLimits map[string]int) *eventBuffer {
+	b := &eventBuffer{main: newRing(limit)}
+	if len(levelLimits) > 0 {
+		b.levels = make(map[string]*ring, len(levelLimits))
+		for level, n := range levelLimits {
+			b.levels[level] = newRing(n)
+		}
+	}
+	return b
+}
+
+func (b *eventBuffer) ringFor(q queued) *ring {
+	if level, ok := q.ev["level"].(string); ok {
+		if r, ok := b.levels[level]; ok {
+			return r
+		}
+	}
+	return b.main
+}
+
+// stamp records the ordering keys used to merge sub-buffers on flush.
+func (b *eventBuffer) stamp(q *queued) {
+	b.next++
+	q.order = b.next
+	switch ts := q.ev["timestamp"].(type) {
+	case int64:
+		q.ts = ts
+	case float64:
+		q.ts = int64(ts)
+	default:
+		q.ts = time.Now().UnixMilli()
+	}
+}
+
+func (b *eventBuffer) push(q queued) bool {
+	b.stamp(&q)
+	return b.ringFor(q).push(q)
+}
+
+// pushFront returns an event taken out for writing to the head of its ring.
+// It keeps the ordering keys stamped when it was first buffered.
+func (b *eventBuffer) pushFront(q queued) bool {
+	if q.order == 0 {
+		b.stamp(&q)
+	}
+	return b.ringFor(q).pushFront(q)
+}
+
+func (b *eventBuffer) len() int {
+	n := b.main.len()
+	for _, r := range b.levels {
+		n += r.len()
+	}
+	return n
+}
+
+func (b *eventBuffer) cap() int {
+	n := b.main.cap()
+	for _, r := range b.levels {
+		n += r.cap()
+	}
+	return n
+}
+
+// pop removes the earliest event across all sub-buffers, by timestamp and
+// then by enqueue order.
+func (b *eventBuffer) pop() (queued, bool) {
+	next := b.main
+	for _, r := range b.levels {
+		if r.len() == 0 {
+			continue
+		}
+		if next.len() == 0 || earlier(r.peek(), next.peek()) {
+			next = r
+		}
+	}
+	return next.pop()
+}
+
+func earlier(a, b queued) bool {
+	if a.ts != b.ts {
+		return a.ts < b.ts
+	}
+	return a.order < b.order
+}
+
+func (b *eventBuffer) drain() []queued {
+	out := make([]queued, 0, b.len())
+	for {
+		q, ok := b.pop()
+		if !ok {
+			return out
+		}
+		out = append(out, q)
+	}
+}
@@ -0,0 +1,97 @@
+package ariabridge
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDebugFloodDoesNotEvictErrors(t *testing.T) {
+	c := NewClient(ClientConfig{
+		URL:               "ws://0.0.0.0:1",
+		BufferLimit:       3,
+		LevelBufferLimits: map[string]int{"error": 2},
+	})
+	_ = c.SendConsole("error", "e0")
+	for i := 0; i < 50; i++ {
+		_ = c.SendConsole("debug", "flood")
+	}
+	_ = c.SendConsole("error", "e1")
+
+	var errs []any
+	for _, q := range c.buffer.drain() {
+		if q.ev["level"] == "error" {
+			errs = append(errs, q.ev["message"])
+		}
+	}
+	if !reflect.DeepEqual([]any{"e0", "e1"}, errs) {
+		t.Fatalf("buffered errors %v", errs)
+	}
+}
+
+func TestSubBuffersFlushInTimestampOrder(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", LevelBufferLimits: map[string]int{"error": 10}})
+	_ = c.enqueue(map[string]any{"type": "console", "level": "info", "message": "a", "timestamp": int64(100)})
+	_ = c.enqueue(map[string]any{"type": "console", "level": "error", "message": "b", "timestamp": int64(200)})
+	_ = c.enqueue(map[string]any{"type": "console", "level": "info", "message": "c", "timestamp": int64(300)})
+	_ = c.enqueue(map[string]any{"type": "console", "level": "error", "message": "d", "timestamp": int64(300)})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+
+	waitFor(t, func() bool { return len(h.ofType("console")) == 4 }, time.Second)
+	var got []any
+	for _, m := range h.ofType("console") {
+		got = append(got, m["message"])
+	}
+	if !reflect.DeepEqual([]any{"a", "b", "c", "d"}, got) {
+		t.Fatalf("flush order %v", got)
+	}
+}
+
+func TestOrderStrictlyIncreasesAcrossConcurrentSends(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	const producers, each = 4, 25
+	var wg sync.WaitGroup
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < each; i++ {
+				_ = c.SendConsole("info", itoa(p)+"-"+itoa(i))
+			}
+		}(p)
+	}
+	wg.Wait()
+	waitFor(t, func() bool { return len(h.ofType("console")) == producers*each }, 2*time.Second)
+
+	seen := map[float64]bool{}
+	last := map[string]float64{}
+	for _, m := range h.ofType("console") {
+		order, _ := m["order"].(float64)
+		if order == 0 || seen[order] {
+			t.Fatalf("order %v repeated or missing", m["order"])
+		}
+		seen[order] = true
+		producer, _, _ := strings.Cut(m["message"].(string), "-")
+		if order <= last[producer] {
+			t.Fatalf("producer %s order went from %v to %v", producer, last[producer], order)
+		}
+		last[producer] = order
+	}
+}
@@ -0,0 +1,92 @@
+package ariabridge
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var ErrCapability = errors.New("capability not advertised")
+
+// defaultCapabilityMap maps event types to the capability that must be
+// advertised before a strict server accepts them. An empty capability means
+// the type is always allowed; unmapped types require a capability of the same
+// name.
+var defaultCapabilityMap = map[string]string{
+	"console":        "console",
+	"error":          "error",
+	"info":           "info",
+	"network":        "network",
+	"control_result": "",
+}
+
+func (c *Client) requiredCapability(typ string) string {
+	if cp, ok := c.cfg.CapabilityMap[typ]; ok {
+		return cp
+	}
+	if cp, ok := defaultCapabilityMap[typ]; ok {
+		return cp
+	}
+	return typ
+}
+
+// checkCapability rejects events whose type was not negotiated when
+// StrictCapabilities is set. Rejected events go to FallbackSink if present.
+func (c *Client) checkCapability(ev map[string]any) error {
+	if !c.cfg.StrictCapabilities {
+		return nil
+	}
+	typ, _ := ev["type"].(string)
+	return c.requireCapability(ev, typ, c.requiredCapability(typ))
+}
+
+func (c *Client) checkQueuedCapability(q queued) error {
+	if q.cp == "" {
+		return c.checkCapability(q.ev)
+	}
+	if !c.cfg.StrictCapabilities {
+		return nil
+	}
+	typ, _ := q.ev["type"].(string)
+	return c.requireCapability(q.ev, typ, q.cp)
+}
+
+func (c *Client) requireCapability(ev map[string]any, typ, cp string) error {
+	if cp == "" || c.hasCapability(cp) {
+		return nil
+	}
+	if c.cfg.FallbackSink != nil {
+		c.cfg.FallbackSink(ev)
+	}
+	return fmt.Errorf("%w: %s event requires %q", ErrCapability, typ, cp)
+}
+
+// InstanceID identifies this client for its lifetime, across reconnects.
+func (c *Client) InstanceID() string {
+	return c.instanceID
+}
+
+// answerCapabilities replies to a server query_capabilities with what the
+// client currently advertises and has negotiated, echoing the query id.
+func (c *Client) answerCapabilities(id any) {
+	c.mu.Lock()
+	caps := append([]string(nil), c.cfg.Capabilities...)
+	c.mu.Unlock()
+	features := []string{}
+	for name, on := range c.ServerFeatures() {
+		if on {
+			features = append(features, name)
+		}
+	}
+	sort.Strings(features)
+	if err := c.send(map[string]any{
+		"type":         "capabilities",
+		"id":           id,
+		"capabilities": caps,
+		"features":     features,
+		"protocol":     ProtocolVersion,
+		"instanceId":   c.instanceID,
+	}); err != nil {
+		c.log("query capabilities: " + err.Error())
+	}
+}
@@ -0,0 +1,72 @@
+package ariabridge
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStrictCapabilitiesAllowsAdvertisedTypes(t *testing.T) {
+	c := NewClient(ClientConfig{URL: "ws://0.0.0.0:1", StrictCapabilities: true})
+	if err := c.SendConsole("info", "ok"); err != nil {
+		t.Fatalf("console rejected: %v", err)
+	}
+	if err := c.enqueue(map[string]any{"type": "control_result", "id": "c1", "ok": true}); err != nil {
+		t.Fatalf("control_result rejected: %v", err)
+	}
+}
+
+func TestStrictCapabilitiesRejectsToFallback(t *testing.T) {
+	rec := &sinkRecorder{}
+	c := NewClient(ClientConfig{URL: "ws://0.0.0.0:1", StrictCapabilities: true, FallbackSink: rec.sink})
+	err := c.enqueue(map[string]any{"type": "network", "message": "GET /"})
+	if !errors.Is(err, ErrCapability) {
+		t.Fatalf("network event returned %v", err)
+	}
+	if got := rec.messages(); len(got) != 1 || got[0] != "GET /" {
+		t.Fatalf("fallback received %v", got)
+	}
+	if c.buffer.len() != 0 {
+		t.Fatalf("rejected event was buffered")
+	}
+}
+
+func TestCapabilityMapOverride(t *testing.T) {
+	c := NewClient(ClientConfig{
+		URL:                "ws://0.0.0.0:1",
+		StrictCapabilities: true,
+		Capabilities:       []string{"console", "telemetry"},
+		CapabilityMap:      map[string]string{"network": "telemetry", "console": ""},
+	})
+	if err := c.enqueue(map[string]any{"type": "network"}); err != nil {
+		t.Fatalf("overridden network rejected: %v", err)
+	}
+	if err := c.enqueue(map[string]any{"type": "trace"}); !errors.Is(err, ErrCapability) {
+		t.Fatalf("unmapped trace returned %v", err)
+	}
+}
+
+func TestQueryCapabilitiesReply(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	h.authInfo = map[string]any{"features": []string{FeatureAcks, FeatureBatching}}
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", Capabilities: []string{"console", "network"}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	h.sendJSON(t, map[string]any{"type": "query_capabilities", "id": "q1"})
+	waitFor(t, func() bool { return len(h.ofType("capabilities")) == 1 }, time.Second)
+	m := h.ofType("capabilities")[0]
+	if m["id"] != "q1" || m["protocol"] != float64(ProtocolVersion) || m["instanceId"] != c.InstanceID() || c.InstanceID() == "" {
+		t.Fatalf("reply %v", m)
+	}
+	if caps := m["capabilities"].([]any); len(caps) != 2 || caps[1] != "network" {
+		t.Fatalf("capabilities %v", caps)
+	}
+	if f := m["features"].([]any); len(f) != 2 || f[0] != FeatureAcks || f[1] != FeatureBatching {
+		t.Fatalf("features %v", f)
+	}
+}
@@ -2,13 +2,16 @@ package ariabridge
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
 	"math/rand"
+	"net"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -21,10 +24,30 @@ const (
 	backoffInitial     = time.Second
 	backoffMax         = 30 * time.Second
 	bufferLimitDefault = 200
+	secretHeader       = "X-Bridge-Secret"
 )
 
+var (
+	ErrAlreadyStarted = errors.New("client already started")
+	ErrClosed         = errors.New("client closed")
+	ErrAuthTimeout    = errors.New("auth_success timeout")
+	ErrConnectionLost = errors.New("connection lost")
+	ErrReadOnly       = errors.New("client is read-only")
+	ErrConnectTimeout = errors.New("connect timeout")
+)
+
+var errNotConnected = errors.New("not connected")
+
 var jitterFn = jitter
 
+// ClientConfig configures a Client. Zero values select the defaults noted
+// here and on the fields below. Capabilities defaults to console, error and
+// info. BufferLimit is 200 when zero, and a negative limit buffers nothing;
+// the same goes for LevelBufferLimits, where a limit of 0 or less means
+// events at that level are never buffered. Eviction applies once a buffer is
+// full, and nil drops the oldest event. AckTimeout and FlowControl only take
+// effect while the server's auth_success lists the acks and flowControl
+// features, or lists none.
 type ClientConfig struct {
 	URL               string
 	Secret            string
@@ -35,23 +58,274 @@ type ClientConfig struct {
 	BackoffInitial    time.Duration
 	BackoffMax        time.Duration
 	BufferLimit       int
+	FallbackSink      func(ev map[string]any)
+	MaxConnectionAge  time.Duration
+	Marshal           func(any) ([]byte, error)
+	Eviction          EvictionPolicy
+	LevelBufferLimits map[string]int
 	Logger            func(string)
+	AckTimeout        time.Duration
+	FlowControl       bool
+	IDGenerator       func() string
+
+	TLSConfig          *tls.Config
+	ClientCertificates []tls.Certificate
+	NetDial            func(ctx context.Context) (net.Conn, error)
+
+	// HeartbeatTimeoutMin and HeartbeatTimeoutMax bound AdaptiveHeartbeat;
+	// they default to HeartbeatTimeout and four times it.
+	AdaptiveHeartbeat   bool
+	HeartbeatTimeoutMin time.Duration
+	HeartbeatTimeoutMax time.Duration
+
+	StrictCapabilities bool
+	CapabilityMap      map[string]string
+
+	// MetricsInterval is 10s when zero and must not be negative.
+	MetricsInterval time.Duration
+	MetricsGzip     bool
+
+	// RehelloDebounce is 250ms when zero.
+	Metadata        map[string]string
+	RehelloDebounce time.Duration
+
+	// ControlDedupWindow is one minute when zero; a negative window turns
+	// control request dedup off.
+	ControlDedupWindow time.Duration
+
+	// WriteQueueSize above zero moves socket writes to a writer goroutine
+	// with a queue that size. A ReadOnly client ignores it.
+	WriteQueueSize      int
+	WriteOverflowPolicy WriteOverflowPolicy
+
+	SecretHeaderName string
+
+	// MaxAuthTimeouts is how many auth timeouts in a row Start tolerates;
+	// at 0 or 1 the first timeout is terminal.
+	MaxAuthTimeouts int
+
+	// OnDemandLevels are only sent while the server has subscribed to them.
+	OnDemandLevels []string
+
+	ValidateHandshakeResponse func(*http.Response) error
+
+	PingPayloadFn func() map[string]any
+
+	FailFastOnFirstDial bool
+
+	// EnableCompression compresses writes longer than CompressionThreshold
+	// bytes while the server lists the compression feature, or lists none.
+	EnableCompression    bool
+	CompressionThreshold int
+
+	// MaxControlResultBytes of 0 or less leaves control results unlimited.
+	MaxControlResultBytes int
+
+	// TeeMaxBytes is 10 MiB when zero.
+	TeeFile     string
+	TeeMaxBytes int64
+
+	// MissedPongsBeforeReconnect of 0 or less never reconnects on missed
+	// pongs alone.
+	MissedPongsBeforeReconnect int
+
+	// MaxMalformedFrames of 0 or less never drops the connection over
+	// malformed frames.
+	MaxMalformedFrames int
+
+	ReconnectCoordinator *ReconnectCoordinator
+
+	// AttachmentChunkSize is 64 KiB when zero or negative.
+	AttachmentChunkSize int
+
+	NDJSONFraming bool
+
+	AuthMode string
+
+	// PostAuthDelay of 0 or less sends hello right after auth_success.
+	PostAuthDelay time.Duration
+
+	LevelRouter func(level string) (eventType string, capability string)
+
+	// PongBufferSize is 8 when zero or negative.
+	PongBufferSize int
+
+	// StatsInterval of 0 or less disables bridge_stats events. Even when set
+	// they are only sent while connected with bridge_stats in Capabilities.
+	StatsInterval time.Duration
+
+	SkipHello bool
+
+	PublishExpvar bool
+	ExpvarPrefix  string
+
+	ShouldReconnect func(err error, attempt int) bool
+
+	FallbackDroppedEvents bool
+
+	// BinaryAcks only takes effect once the server lists binaryAcks.
+	BinaryAcks bool
+
+	// ReadOnly rejects every send with ErrReadOnly and advertises the
+	// readonly capability; control requests are still answered.
+	ReadOnly bool
+
+	// ConnectTimeout of 0 or less puts no overall limit on a connect attempt.
+	ConnectTimeout time.Duration
+
+	SchemaValidator SchemaValidator
+
+	// MaxEventDepth and MaxEventBytes of 0 or less leave events unlimited.
+	MaxEventDepth int
+	MaxEventBytes int
+
+	Authenticator Authenticator
+
+	// IdempotencyWindow is one minute when zero; a negative window turns
+	// SendIdempotent dedup off.
+	IdempotencyWindow time.Duration
+
+	FlushOnDeadline bool
+
+	ChainSequence bool
+
+	// IdleTimeout of 0 or less keeps an idle connection open.
+	IdleTimeout time.Duration
 }
 
 type Client struct {
+	*core
+	fields map[string]any
+
+	scopeMu sync.Mutex
+	scopes  []scope
+}
+
+type core struct {
 	cfg            ClientConfig
 	conn           *websocket.Conn
+	sinkQueue      []map[string]any
+	frameQueue     [][]byte
+	prevSeq        int64
+	alive          *atomic.Bool
 	cancel         context.CancelFunc
-	pongCh         chan struct{}
+	mu             sync.Mutex
+	stop           context.CancelFunc
+	started        bool
+	initialConn    net.Conn
+	closed         bool
+	minLevel       string
+	boostLevel     string
+	boostUntil     time.Time
+	subscribed     map[string]bool
+	writeMu        sync.Mutex
+	seq            int64
+	eventSeq       atomic.Uint64
+	orderSeq       atomic.Uint64
+	querySeq       atomic.Uint64
+	queries        map[string]*pendingQuery
+	life           context.Context
+	endLife        context.CancelFunc
+	ready          chan struct{}
+	readyOnce      sync.Once
+	actions        map[string]func(ControlRequest) (any, error)
+	unknownAction  func(ControlRequest) (any, error)
+	avgRTT         time.Duration
+	state          string
+	stateSince     time.Time
+	upFor          time.Duration
+	downFor        time.Duration
+	stateHandler   func(old, new string)
+	flushComplete  func(sent, remaining int)
+	pending        map[int64]*pendingAck
+	credits        int
+	pressure       []*pressureWatch
+	skew           time.Duration
+	hbSuspended    bool
+	rehelloTimer   *time.Timer
+	controlSeen    map[string]controlEntry
+	idempotent     map[string]time.Time
+	writeQ         chan queued
+	writerDone     <-chan struct{}
+	inflight       atomic.Int64
+	lastSend       atomic.Int64
+	clockNow       func() time.Time
+	lastActive     atomic.Int64
+	idleWake       chan struct{}
+	pingGen        atomic.Uint64
+	tee            *teeFile
+	reconnectCh    chan struct{}
+	backoffReset   chan struct{}
+	instanceID     string
+	expvarName     string
+	stats          stats
+	protocolError  func(raw []byte, err error)
+	features       map[string]bool
+	controls       sync.WaitGroup
+	shuttingDown   bool
+	metricsMu      sync.Mutex
+	metrics        map[string]*metricAgg
+	sendResult     func(map[string]any, error)
+	serverHandlers map[string]func([]byte) error
+	serverFallback func(map[string]any)
+	pongCh         chan pong
 	bufMu          sync.Mutex
-	buffer         []map[string]any
+	buffer         *eventBuffer
 	dropped        int
 	controlHandler func(map[string]any) (any, error)
 }
 
+type queued struct {
+	ev  map[string]any
+	raw []byte
+	seq int64
+	cp  string
+
+	deadline time.Time
+	// a FlowControl credit was spent on q, returned if it expires unwritten
+	credit bool
+
+	// binary frames written right after ev, for attachments
+	frames [][]byte
+
+	ts    int64
+	order uint64
+}
+
 func NewClient(cfg ClientConfig) *Client {
+	if cfg.ReadOnly {
+		cfg.Capabilities = readOnlyCapabilities(cfg.Capabilities)
+		cfg.WriteQueueSize = 0
+	}
 	if len(cfg.Capabilities) == 0 {
-		cfg.Capabilities = []string{"console", "error"}
+		cfg.Capabilities = []string{"console", "error", "info"}
+	}
+	if cfg.Marshal == nil {
+		cfg.Marshal = json.Marshal
+	}
+	if cfg.PongBufferSize <= 0 {
+		cfg.PongBufferSize = pongBufferDefault
+	}
+	if cfg.AttachmentChunkSize <= 0 {
+		cfg.AttachmentChunkSize = attachmentChunkDefault
+	}
+	if cfg.TeeMaxBytes == 0 {
+		cfg.TeeMaxBytes = teeMaxBytesDefault
+	}
+	if cfg.SecretHeaderName == "" {
+		cfg.SecretHeaderName = secretHeader
+	}
+	if cfg.ControlDedupWindow == 0 {
+		cfg.ControlDedupWindow = controlDedupWindowDefault
+	}
+	if cfg.IdempotencyWindow == 0 {
+		cfg.IdempotencyWindow = idempotencyWindowDefault
+	}
+	if cfg.RehelloDebounce == 0 {
+		cfg.RehelloDebounce = rehelloDebounceDefault
+	}
+	if cfg.MetricsInterval == 0 {
+		cfg.MetricsInterval = metricsIntervalDefault
 	}
 	if cfg.HeartbeatInterval == 0 {
 		cfg.HeartbeatInterval = HeartbeatInterval
@@ -59,6 +333,12 @@ func NewClient(cfg ClientConfig) *Client {
 	if cfg.HeartbeatTimeout == 0 {
 		cfg.HeartbeatTimeout = HeartbeatTimeout
 	}
+	if cfg.HeartbeatTimeoutMin == 0 {
+		cfg.HeartbeatTimeoutMin = cfg.HeartbeatTimeout
+	}
+	if cfg.HeartbeatTimeoutMax == 0 {
+		cfg.HeartbeatTimeoutMax = 4 * cfg.HeartbeatTimeout
+	}
 	if cfg.BackoffInitial == 0 {
 		cfg.BackoffInitial = backoffInitial
 	}
@@ -68,21 +348,118 @@ func NewClient(cfg ClientConfig) *Client {
 	if cfg.BufferLimit == 0 {
 		cfg.BufferLimit = bufferLimitDefault
 	}
-	return &Client{cfg: cfg, pongCh: make(chan struct{}, 1)}
+	c := &core{cfg: cfg, pongCh: make(chan pong, cfg.PongBufferSize), reconnectCh: make(chan struct{}, 1), backoffReset: make(chan struct{}, 1), buffer: newEventBuffer(cfg.BufferLimit, cfg.LevelBufferLimits), pending: map[int64]*pendingAck{}}
+	c.instanceID = fmt.Sprintf("%08x%08x", rand.Uint32(), rand.Uint32())
+	c.life, c.endLife = context.WithCancel(context.Background())
+	c.ready = make(chan struct{})
+	c.idleWake = make(chan struct{}, 1)
+	c.clockNow = time.Now
+	if cfg.WriteQueueSize > 0 {
+		c.writeQ = make(chan queued, cfg.WriteQueueSize)
+	}
+	c.lastSend.Store(int64(monoNow()))
+	if cfg.TeeFile != "" {
+		c.tee = &teeFile{path: cfg.TeeFile, max: cfg.TeeMaxBytes}
+	}
+	cl := &Client{core: c}
+	if cfg.PublishExpvar {
+		c.expvarName = publishExpvar(cfg.ExpvarPrefix, cl)
+	}
+	return cl
 }
 
+// Start runs the connect loop until ctx is done or the client is closed. A
+// client is single-use: it can be started once and not restarted after Close.
 func (c *Client) Start(ctx context.Context) error {
-	return c.run(ctx)
+	return c.start(ctx, nil)
+}
+
+// StartWithConn is like Start but performs the first websocket handshake over
+// nc instead of dialing. Reconnects use NetDial when set, or dial URL.
+func (c *Client) StartWithConn(ctx context.Context, nc net.Conn) error {
+	return c.start(ctx, nc)
 }
 
+func (c *Client) start(ctx context.Context, nc net.Conn) error {
+	// a dead context leaves the client untouched and startable
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return ErrClosed
+	}
+	if c.started {
+		c.mu.Unlock()
+		return ErrAlreadyStarted
+	}
+	c.started = true
+	c.initialConn = nc
+	ctx, stop := context.WithCancel(ctx)
+	c.stop = stop
+	c.mu.Unlock()
+	defer stop()
+	defer c.endLife()
+	defer c.stopAcks()
+	defer c.setState(StateClosed)
+	if !c.cfg.ReadOnly {
+		go c.metricsLoop(ctx)
+	}
+	if c.cfg.StatsInterval > 0 {
+		go c.statsLoop(ctx)
+	}
+	err := c.run(ctx)
+	if err != nil && ctx.Err() == nil {
+		c.drainToFallback()
+	}
+	return err
+}
+
+// Close makes a best-effort final flush, sends a close frame and tears the
+// connection down. Any failures along the way are joined into the result.
 func (c *Client) Close() error {
+	var errs []error
+	c.bufMu.Lock()
+	conn := c.conn
+	if conn != nil {
+		if err := c.flushLocked(); err != nil {
+			errs = append(errs, fmt.Errorf("final flush: %w", err))
+		}
+	} else {
+		c.reportDrops(true)
+	}
+	c.unlockBuf()
+	if conn != nil {
+		c.waitWrites(writeDrainTimeout)
+		msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+		if err := conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second)); err != nil && !errors.Is(err, websocket.ErrCloseSent) {
+			errs = append(errs, fmt.Errorf("close handshake: %w", err))
+		}
+	}
+
+	c.mu.Lock()
+	c.closed = true
+	if c.stop != nil {
+		c.stop()
+	}
+	c.mu.Unlock()
+	c.endLife()
+	c.stopAcks()
 	if c.cancel != nil {
 		c.cancel()
 	}
-	if c.conn != nil {
-		return c.conn.Close()
+	if conn != nil {
+		if err := conn.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			errs = append(errs, err)
+		}
 	}
-	return nil
+	if c.tee != nil {
+		if err := c.tee.close(); err != nil {
+			errs = append(errs, fmt.Errorf("tee file: %w", err))
+		}
+	}
+	return errors.Join(errs...)
 }
 
 func (c *Client) SendConsole(level, message string) error {
@@ -90,49 +467,316 @@ func (c *Client) SendConsole(level, message string) error {
 	return c.enqueue(payload)
 }
 
+// SendConsoleLines enqueues one console event per line under a single buffer
+// lock, so the lines stay contiguous and in order.
+func (c *Client) SendConsoleLines(level string, lines []string) error {
+	if err := c.checkWritable(nil); err != nil {
+		return err
+	}
+	qs := make([]queued, 0, len(lines))
+	for _, line := range lines {
+		ev := map[string]any{"type": "console", "level": level, "message": line, "timestamp": time.Now().UnixMilli()}
+		c.stampFields(ev)
+		q := queued{ev: ev}
+		if ok, err := c.prepare(&q); !ok {
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		qs = append(qs, q)
+	}
+	defer c.checkPressure()
+	c.bufMu.Lock()
+	defer c.unlockBuf()
+	var errs []error
+	for _, q := range qs {
+		if err := c.pushLocked(q); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SendEvent enqueues a copy of ev, which must carry a string type.
+func (c *Client) SendEvent(ev map[string]any) error {
+	if t, _ := ev["type"].(string); t == "" {
+		return errors.New("event missing type")
+	}
+	return c.enqueue(copyEvent(ev))
+}
+
+func (c *Client) SendRaw(data []byte) error {
+	var ev map[string]any
+	if err := json.Unmarshal(data, &ev); err != nil {
+		return fmt.Errorf("raw event: %w", err)
+	}
+	if t, _ := ev["type"].(string); t == "" {
+		return errors.New("raw event missing type")
+	}
+	return c.push(queued{ev: ev, raw: append([]byte(nil), data...)})
+}
+
 func (c *Client) OnControl(handler func(map[string]any) (any, error)) {
 	c.controlHandler = handler
 }
 
-func (c *Client) send(obj map[string]any) error {
-	data, _ := json.Marshal(obj)
+func (c *Client) send(obj any) error {
+	data, err := c.cfg.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	return c.writeRaw(data)
+}
+
+func (c *Client) writeRaw(data []byte) error {
+	if c.cfg.NDJSONFraming {
+		line, err := ndjsonLine(data)
+		if err != nil {
+			return err
+		}
+		data = line
+	}
+	compress := c.cfg.EnableCompression && c.featureEnabled(FeatureCompression)
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.conn == nil {
+		return errNotConnected
+	}
+	if c.cfg.EnableCompression {
+		c.conn.EnableWriteCompression(compress && len(data) > c.cfg.CompressionThreshold)
+	}
 	return c.conn.WriteMessage(websocket.TextMessage, data)
 }
 
+func (c *Client) sendQueued(q queued) error {
+	if done := c.activeWriter(); done != nil {
+		return c.queueWrite(q, done)
+	}
+	// callers hold bufMu; attachment frames go out once it is released
+	frames := q.frames
+	q.frames = nil
+	if err := c.writeQueued(q); errors.Is(err, ErrExpired) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	c.frameQueue = append(c.frameQueue, frames...)
+	return nil
+}
+
 func (c *Client) enqueue(ev map[string]any) error {
+	return c.enqueueBy(ev, time.Time{})
+}
+
+// enqueueBy is enqueue for an event that expires at deadline, if set.
+func (c *Client) enqueueBy(ev map[string]any, deadline time.Time) error {
+	c.stampFields(ev)
+	return c.push(queued{ev: ev, deadline: deadline})
+}
+
+func (c *Client) push(q queued) error {
+	if ok, err := c.prepare(&q); !ok {
+		return err
+	}
+	defer c.checkPressure()
 	c.bufMu.Lock()
-	defer c.bufMu.Unlock()
-	if c.conn != nil {
-		if err := c.send(ev); err != nil {
+	defer c.unlockBuf()
+	return c.pushLocked(q)
+}
+
+// prepare runs every check and stamp an event gets before it is sent or
+// buffered. It reports false when q must not be pushed, with the error if it
+// was rejected rather than filtered out by level.
+func (c *Client) prepare(q *queued) (bool, error) {
+	if err := c.checkWritable(q.ev); err != nil {
+		return false, err
+	}
+	if !c.levelAllowed(q.ev) {
+		return false, nil
+	}
+	if q.raw == nil {
+		c.route(q)
+	}
+	if err := c.checkEventLimits(*q); err != nil {
+		return false, err
+	}
+	if err := c.checkQueuedCapability(*q); err != nil {
+		return false, err
+	}
+	c.stampEventID(*q)
+	c.stampOrder(*q)
+	c.stampSeq(q)
+	if err := c.checkSchema(*q); err != nil {
+		return false, err
+	}
+	c.teeEvent(*q)
+	return true, nil
+}
+
+// pushLocked sends q straight away when possible and buffers it otherwise.
+// The caller holds bufMu.
+func (c *Client) pushLocked(q queued) error {
+	if q.expiredAt(time.Now()) {
+		c.expired(q)
+		return nil
+	}
+	q.credit = false
+	if c.connAlive() && c.buffer.len() == 0 && c.takeCredit() {
+		q.credit = c.flowControl()
+		if err := c.sendQueued(q); !errors.Is(err, errWriterStopped) {
 			return err
 		}
 		return nil
 	}
-	if len(c.buffer) >= c.cfg.BufferLimit {
-		c.buffer = c.buffer[1:]
+	c.wakeIdle()
+	if r := c.buffer.ringFor(q); c.cfg.Eviction != nil && r.len() >= r.cap() {
+		c.buffer.stamp(&q)
+		c.dropped += c.evict(r, q)
+		return nil
+	}
+	var victim map[string]any
+	if r := c.buffer.ringFor(q); r.cap() == 0 {
+		victim = q.ev
+	} else if r.len() >= r.cap() {
+		victim = r.peek().ev
+	}
+	if c.buffer.push(q) {
 		c.dropped++
+		c.fallbackDropped(victim)
 	}
-	c.buffer = append(c.buffer, ev)
 	return nil
 }
 
-func (c *Client) flushBuffer() {
-	c.bufMu.Lock()
-	defer c.bufMu.Unlock()
-	if c.conn == nil {
+func (c *Client) stampEventID(q queued) {
+	if q.raw != nil {
 		return
 	}
-	for _, ev := range c.buffer {
-		_ = c.send(ev)
+	if _, ok := q.ev["eventId"]; ok {
+		return
+	}
+	if c.cfg.IDGenerator != nil {
+		q.ev["eventId"] = c.cfg.IDGenerator()
+		return
+	}
+	q.ev["eventId"] = fmt.Sprintf("%d-%08x", c.eventSeq.Add(1), rand.Uint32())
+}
+
+// stampOrder records production order. Unlike seq it is assigned to every
+// event and never resets, so it survives reconnects and restores.
+func (c *Client) stampOrder(q queued) {
+	if q.raw != nil {
+		return
+	}
+	if _, ok := q.ev["order"]; !ok {
+		q.ev["order"] = c.orderSeq.Add(1)
+	}
+}
+
+func (c *Client) setConn(conn *websocket.Conn) {
+	c.bufMu.Lock()
+	c.writeMu.Lock()
+	c.conn = conn
+	c.prevSeq = 0
+	c.writeMu.Unlock()
+	c.alive = nil
+	c.bufMu.Unlock()
+}
+
+// markAlive opens the current connection for events once the handshake is
+// done. The reader clears the returned flag as soon as it exits, so events
+// are buffered rather than written to a socket that is being torn down.
+func (c *Client) markAlive() *atomic.Bool {
+	alive := new(atomic.Bool)
+	alive.Store(true)
+	c.bufMu.Lock()
+	c.alive = alive
+	c.bufMu.Unlock()
+	return alive
+}
+
+// connAlive reports whether events may be written directly. The caller holds
+// bufMu.
+func (c *Client) connAlive() bool {
+	return c.conn != nil && c.alive != nil && c.alive.Load()
+}
+
+func (c *Client) flushBuffer() error {
+	defer c.checkPressure()
+	c.bufMu.Lock()
+	if !c.connAlive() {
+		c.bufMu.Unlock()
+		return nil
 	}
-	c.buffer = nil
-	if c.dropped > 0 {
-		_ = c.send(map[string]any{
+	before := c.buffer.len()
+	err := c.flushLocked()
+	remaining := c.buffer.len()
+	c.unlockBuf()
+	c.notifyFlush(before, remaining)
+	return err
+}
+
+// flushLocked sends as much of the buffer as credits allow. The caller holds
+// bufMu.
+func (c *Client) flushLocked() error {
+	return c.flushWith(true)
+}
+
+// flushWith writes out the buffer, spending a FlowControl credit per event
+// when useCredits is set. Expired events are dropped before a credit is
+// spent on them. The caller holds bufMu.
+func (c *Client) flushWith(useCredits bool) error {
+	var errs []error
+	for c.buffer.len() > 0 {
+		q, _ := c.buffer.pop()
+		if q.expiredAt(time.Now()) {
+			c.expired(q)
+			continue
+		}
+		q.credit = false
+		if useCredits {
+			if !c.takeCredit() {
+				c.buffer.pushFront(q)
+				break
+			}
+			q.credit = c.flowControl()
+		}
+		if err := c.sendQueued(q); errors.Is(err, errWriterStopped) {
+			break
+		} else if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	sent := false
+	if c.dropped > 0 && c.hasCapability("info") {
+		if err := c.send(map[string]any{
 			"type":    "info",
 			"level":   "info",
 			"message": "bridge buffered drop count=" + itoa(c.dropped),
-		})
-		c.dropped = 0
+		}); err != nil {
+			errs = append(errs, err)
+		} else {
+			sent = true
+		}
+	}
+	c.reportDrops(!sent)
+	return errors.Join(errs...)
+}
+
+func (c *Client) hasCapability(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, cp := range c.cfg.Capabilities {
+		if cp == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Client) log(msg string) {
+	if c.cfg.Logger != nil {
+		c.cfg.Logger(msg)
 	}
 }
 
@@ -140,58 +784,127 @@ func itoa(v int) string {
 	return fmt.Sprintf("%d", v)
 }
 
-func (c *Client) heartbeat(ctx context.Context) {
+func (c *Client) heartbeat(ctx context.Context, cancel context.CancelFunc, conn *websocket.Conn) {
 	ticker := time.NewTicker(c.cfg.HeartbeatInterval)
 	defer ticker.Stop()
+	var pingAt time.Time
+	var gen uint64
+	var nonce map[string]any
+	missed := 0
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			_ = c.send(map[string]any{"type": "ping"})
-			c.conn.SetReadDeadline(time.Now().Add(c.cfg.HeartbeatTimeout))
-		case <-c.pongCh:
-			c.conn.SetReadDeadline(time.Now().Add(c.cfg.HeartbeatTimeout))
+			if c.heartbeatSuspended() {
+				continue
+			}
+			if !pingAt.IsZero() && c.cfg.MissedPongsBeforeReconnect > 0 {
+				missed++
+				if missed >= c.cfg.MissedPongsBeforeReconnect {
+					c.log("heartbeat: " + itoa(missed) + " pongs missed, reconnecting")
+					cancel()
+					return
+				}
+			}
+			gen = c.pingGen.Add(1)
+			pingAt = c.clockNow()
+			var ping any
+			ping, nonce = c.pingMessage()
+			_ = c.send(ping)
+			c.extendDeadline(conn)
+		case p := <-c.pongCh:
+			if !pingAt.IsZero() && p.gen == gen && pongMatches(nonce, p.msg) {
+				missed = 0
+				if rtt := p.at.Sub(pingAt); rtt > 0 {
+					c.observeRTT(rtt)
+				}
+				pingAt = time.Time{}
+			}
+			c.extendDeadline(conn)
 		}
 	}
 }
 
-func (c *Client) reader(ctx context.Context, cancel context.CancelFunc) {
+func (c *Client) reader(ctx context.Context, cancel context.CancelFunc, conn *websocket.Conn, alive *atomic.Bool) {
 	defer cancel()
+	defer alive.Store(false)
+	malformed := 0
 	for {
-		_, data, err := c.conn.ReadMessage()
+		typ, data, err := conn.ReadMessage()
 		if err != nil {
 			return
 		}
+		if typ == websocket.BinaryMessage && c.binaryAcks() {
+			if seq, ok := binaryAck(data); ok {
+				malformed = 0
+				c.resolveAck(seq)
+				c.grantCredits(1)
+				continue
+			}
+		}
 		var m map[string]any
 		if err := json.Unmarshal(data, &m); err != nil {
+			malformed++
+			if c.malformedFrame(data, err, malformed) {
+				c.log("reader: too many malformed frames, reconnecting")
+				return
+			}
 			continue
 		}
+		malformed = 0
 		if t, ok := m["type"].(string); ok {
 			switch t {
 			case "ping":
-				_ = c.send(map[string]any{"type": "pong"})
+				_ = c.send(PingMessage{Type: "pong"})
 			case "pong":
-				select {
-				case c.pongCh <- struct{}{}:
-				default:
-				}
+				c.observeServerTime(m)
+				c.deliverPong(pong{msg: m, at: c.clockNow(), gen: c.pingGen.Load()})
 			case "control_request":
+				c.lastActive.Store(int64(monoNow()))
 				c.handleControl(m)
+			case "ack":
+				if seq, ok := m["seq"].(float64); ok {
+					c.resolveAck(int64(seq))
+					c.grantCredits(1)
+				}
+			case "credit":
+				n, _ := m["n"].(float64)
+				c.grantCredits(int(n))
+			case "flush":
+				c.serverFlush()
+			case "subscribe", "unsubscribe":
+				c.setSubscribed(m, t == "subscribe")
+			case "logs":
+				c.deliverQuery(m)
+			case "set_level":
+				level, _ := m["level"].(string)
+				c.setLevel(level)
+			case "query_capabilities":
+				c.answerCapabilities(m["id"])
+			default:
+				c.dispatchServerMessage(t, data, m)
 			}
 		}
 	}
 }
 
-func (c *Client) waitForAuth(ctx context.Context) error {
+func (c *Client) waitForAuth(ctx context.Context, conn *websocket.Conn) error {
 	deadline := time.Now().Add(c.cfg.HeartbeatTimeout)
+	if dl, ok := ctx.Deadline(); ok && dl.Before(deadline) {
+		deadline = dl
+	}
 	for {
 		if time.Now().After(deadline) {
-			return errors.New("auth_success timeout")
+			return c.authDeadlineErr(ctx)
 		}
-		c.conn.SetReadDeadline(deadline)
-		_, data, err := c.conn.ReadMessage()
+		conn.SetReadDeadline(deadline)
+		_, data, err := conn.ReadMessage()
 		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				return c.authDeadlineErr(ctx)
+			}
 			return err
 		}
 		var m map[string]any
@@ -199,9 +912,11 @@ func (c *Client) waitForAuth(ctx context.Context) error {
 		t, _ := m["type"].(string)
 		switch t {
 		case "auth_success":
-			return nil
+			c.observeServerTime(m)
+			c.observeFeatures(m)
+			return checkProtocolRange(m)
 		case "ping":
-			_ = c.send(map[string]any{"type": "pong"})
+			_ = c.send(PingMessage{Type: "pong"})
 		case "pong":
 			// ignore
 		}
@@ -212,8 +927,26 @@ func (c *Client) handleControl(msg map[string]any) {
 	if c.controlHandler == nil {
 		return
 	}
+	id := controlID(msg)
+	if cached, ok := c.cachedControl(id); ok {
+		_ = c.enqueue(cached)
+		return
+	}
+	if !c.beginControl() {
+		_ = c.enqueue(map[string]any{
+			"type":  "control_result",
+			"id":    msg["id"],
+			"ok":    false,
+			"error": map[string]any{"message": ErrShuttingDown.Error()},
+		})
+		return
+	}
+	defer c.controls.Done()
 	var resp map[string]any
 	result, err := c.controlHandler(msg)
+	if err == nil {
+		err = c.checkControlResult(result)
+	}
 	if err != nil {
 		resp = map[string]any{
 			"type":  "control_result",
@@ -229,54 +962,360 @@ func (c *Client) handleControl(msg map[string]any) {
 			"result": result,
 		}
 	}
+	if id != "" {
+		c.rememberControl(id, resp)
+	}
 	_ = c.enqueue(resp)
 }
 
-func jitter(d time.Duration) time.Duration {
-	f := rand.Float64()*0.5 + 1.0 // 1.0 - 1.5x
-	return time.Duration(float64(d) * f)
+// jitter picks a delay at most d from a window below it that widens with
+// each consecutive failed attempt: the top quarter of d after the first, the
+// top third after the second, approaching d/2-d. Jittering downward keeps
+// the spread when d is already BackoffMax.
+func jitter(d time.Duration, attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	w := 0.5 * float64(attempt) / float64(attempt+1)
+	return time.Duration(float64(d) * (1 - rand.Float64()*w))
+}
+
+// shouldReconnect consults ShouldReconnect before a re-dial. attempt counts
+// re-dials since the last successful handshake.
+func (c *Client) shouldReconnect(err error, attempt int) bool {
+	if c.cfg.ShouldReconnect == nil {
+		return true
+	}
+	return c.cfg.ShouldReconnect(err, attempt)
+}
+
+// ResetBackoff returns the reconnect delay to BackoffInitial. A retry that is
+// currently waiting fires after BackoffInitial instead.
+func (c *Client) ResetBackoff() {
+	select {
+	case c.backoffReset <- struct{}{}:
+	default:
+	}
+}
+
+// backoffSleep waits out the jittered delay and returns the delay for the
+// next attempt.
+func (c *Client) backoffSleep(ctx context.Context, delay time.Duration, attempt int) (time.Duration, error) {
+	d := delay
+	if d > c.cfg.BackoffMax {
+		d = c.cfg.BackoffMax
+	}
+	return c.sleepBackoff(ctx, jitterFn(d, attempt), delay)
+}
+
+// sleepBackoff sleeps for d. A ResetBackoff during the sleep cuts it to
+// BackoffInitial and restarts the doubling from there.
+func (c *Client) sleepBackoff(ctx context.Context, d, delay time.Duration) (time.Duration, error) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return delay, ctx.Err()
+	case <-t.C:
+	case <-c.backoffReset:
+		delay = c.cfg.BackoffInitial
+		if err := sleepCtx(ctx, delay); err != nil {
+			return delay, err
+		}
+	}
+	return time.Duration(math.Min(float64(c.cfg.BackoffMax), float64(delay)*2)), nil
+}
+
+func (c *Client) netDial(ctx context.Context, network, addr string) (net.Conn, error) {
+	c.mu.Lock()
+	nc := c.initialConn
+	c.initialConn = nil
+	c.mu.Unlock()
+	if nc != nil {
+		return nc, nil
+	}
+	if c.cfg.NetDial != nil {
+		return c.cfg.NetDial(ctx)
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, network, addr)
+}
+
+func (c *Client) tlsConfig() *tls.Config {
+	if c.cfg.TLSConfig == nil && len(c.cfg.ClientCertificates) == 0 {
+		return nil
+	}
+	cfg := &tls.Config{}
+	if c.cfg.TLSConfig != nil {
+		cfg = c.cfg.TLSConfig.Clone()
+	}
+	cfg.Certificates = append(cfg.Certificates, c.cfg.ClientCertificates...)
+	return cfg
+}
+
+// waitConnection blocks until the connection ends. When MaxConnectionAge is
+// reached it flushes, closes gracefully and reports that a redial is due.
+// After IdleTimeout without activity it closes the same way and reports idle
+// instead, so the next event redials.
+func (c *Client) waitConnection(ctx context.Context, cancel context.CancelFunc, conn *websocket.Conn) (recycled, idle bool) {
+	var ageC <-chan time.Time
+	if c.cfg.MaxConnectionAge > 0 {
+		age := time.NewTimer(c.cfg.MaxConnectionAge)
+		defer age.Stop()
+		ageC = age.C
+	}
+	var idleTimer *time.Timer
+	var idleC <-chan time.Time
+	if c.cfg.IdleTimeout > 0 {
+		c.lastActive.Store(int64(monoNow()))
+		idleTimer = time.NewTimer(c.cfg.IdleTimeout)
+		defer idleTimer.Stop()
+		idleC = idleTimer.C
+	}
+	var reason string
+	for reason == "" {
+		select {
+		case <-ctx.Done():
+			return false, false
+		case <-ageC:
+			reason = "max connection age"
+		case <-c.reconnectCh:
+			reason = "secret rotated"
+		case <-idleC:
+			if rest := c.cfg.IdleTimeout - c.idleFor(); rest > 0 {
+				idleTimer.Reset(rest)
+				continue
+			}
+			// only events from here on should wake the client
+			select {
+			case <-c.idleWake:
+			default:
+			}
+			reason = "idle"
+			idle = true
+		}
+	}
+	_ = c.flushBuffer()
+	msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, reason)
+	_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+	cancel()
+	return !idle, idle
+}
+
+func (c *Client) handshake(ctx context.Context, conn *websocket.Conn) error {
+	if c.cfg.Authenticator != nil {
+		return c.authenticate(ctx, conn)
+	}
+	conn.SetReadDeadline(time.Now().Add(c.cfg.HeartbeatTimeout))
+	auth, err := c.authMessage()
+	if err != nil {
+		return err
+	}
+	if err := c.send(auth); err != nil {
+		return err
+	}
+	if err := c.waitForAuth(ctx, conn); err != nil {
+		if errors.Is(err, ErrProtocolMismatch) {
+			msg := websocket.FormatCloseMessage(websocket.CloseProtocolError, "protocol mismatch")
+			_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+		}
+		return err
+	}
+	if c.cfg.PostAuthDelay > 0 {
+		if err := sleepCtx(ctx, c.cfg.PostAuthDelay); err != nil {
+			return err
+		}
+	}
+	if c.cfg.SkipHello {
+		return nil
+	}
+	return c.send(c.helloMessage("hello"))
 }
 
 func (c *Client) run(ctx context.Context) error {
 	delay := c.cfg.BackoffInitial
+	authTimeouts := 0
+	firstDial := true
+	failures := 0
+	attempts := 0
+	c.setState(StateConnecting)
 	for {
 		if ctx.Err() != nil {
 			return ctx.Err()
 		}
-		d := websocket.Dialer{HandshakeTimeout: 5 * time.Second}
-		conn, _, err := d.DialContext(ctx, c.cfg.URL, http.Header{"X-Bridge-Secret": []string{c.cfg.Secret}})
+		// this connection will use the current secret
+		select {
+		case <-c.reconnectCh:
+		default:
+		}
+		if rc := c.cfg.ReconnectCoordinator; rc != nil {
+			if err := rc.Wait(ctx); err != nil {
+				return err
+			}
+		}
+		d := websocket.Dialer{HandshakeTimeout: 5 * time.Second, TLSClientConfig: c.tlsConfig(), NetDialContext: c.netDial, EnableCompression: c.cfg.EnableCompression}
+		connCtx, connDone := c.connectContext(ctx)
+		conn, resp, err := d.DialContext(connCtx, c.cfg.URL, c.handshakeHeader())
 		if err != nil {
-			time.Sleep(jitterFn(delay))
-			delay = time.Duration(math.Min(float64(c.cfg.BackoffMax), float64(delay)*2))
-			continue
+			connDone()
+			err = connectErr(ctx, connCtx, err)
 		}
-		c.conn = conn
-		delay = c.cfg.BackoffInitial
-
-		c.conn.SetReadDeadline(time.Now().Add(c.cfg.HeartbeatTimeout))
-		if err := c.send(map[string]any{"type": "auth", "secret": c.cfg.Secret, "role": "bridge"}); err != nil {
-			return err
+		if err == nil && c.cfg.ValidateHandshakeResponse != nil {
+			if err = c.cfg.ValidateHandshakeResponse(resp); err != nil {
+				c.log("handshake response rejected: " + err.Error())
+				_ = conn.Close()
+				connDone()
+			}
 		}
-		if err := c.waitForAuth(ctx); err != nil {
+		if err != nil && firstDial && c.cfg.FailFastOnFirstDial {
 			return err
 		}
-		if err := c.send(map[string]any{"type": "hello", "capabilities": c.cfg.Capabilities, "platform": "go", "projectId": c.cfg.ProjectID, "protocol": ProtocolVersion}); err != nil {
-			return err
+		firstDial = false
+		if err != nil {
+			failures++
+			attempts++
+			if !c.shouldReconnect(err, attempts) {
+				return err
+			}
+			if delay, err = c.backoffSleep(ctx, delay, failures); err != nil {
+				return err
+			}
+			continue
+		}
+		failures = 0
+		c.setConn(conn)
+		c.resetCredits()
+		c.resetSubscriptions()
+
+		err = connectErr(ctx, connCtx, c.handshake(connCtx, conn))
+		connDone()
+		if err != nil {
+			_ = conn.Close()
+			c.setConn(nil)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			// a connect that ran out of ConnectTimeout is retried like a
+			// failed dial
+			if errors.Is(err, ErrConnectTimeout) {
+				c.log(err.Error())
+				failures++
+				attempts++
+				if !c.shouldReconnect(err, attempts) {
+					return err
+				}
+				if delay, err = c.backoffSleep(ctx, delay, failures); err != nil {
+					return err
+				}
+				continue
+			}
+			// auth timeouts are retried up to MaxAuthTimeouts in a row; any
+			// other handshake failure is terminal
+			if !errors.Is(err, ErrAuthTimeout) {
+				return err
+			}
+			authTimeouts++
+			if authTimeouts >= c.cfg.MaxAuthTimeouts {
+				return fmt.Errorf("%w after %d attempts", err, authTimeouts)
+			}
+			attempts++
+			if !c.shouldReconnect(err, attempts) {
+				return err
+			}
+			if delay, err = c.backoffSleep(ctx, delay, authTimeouts); err != nil {
+				return err
+			}
+			continue
 		}
-		c.flushBuffer()
+		authTimeouts = 0
+		attempts = 0
+		c.stats.connects.Add(1)
+		delay = c.cfg.BackoffInitial
+		select {
+		case <-c.backoffReset:
+		default:
+		}
+		c.extendDeadline(conn)
+		alive := c.markAlive()
+		_ = c.flushBuffer()
 
 		hbCtx, cancel := context.WithCancel(ctx)
 		c.cancel = cancel
-		go c.reader(hbCtx, cancel)
-		go c.heartbeat(hbCtx)
+		var writerExited <-chan struct{}
+		if c.writeQ != nil {
+			writerExited = c.startWriter(hbCtx, cancel)
+		}
+		c.setState(StateConnected)
+		c.readyOnce.Do(func() { close(c.ready) })
+		go c.reader(hbCtx, cancel, conn, alive)
+		go c.heartbeat(hbCtx, cancel, conn)
 
-		// wait for reader or context cancellation
-		<-hbCtx.Done()
-		if c.conn != nil {
-			_ = c.conn.Close()
+		// wait for reader, context cancellation, the connection aging out or a
+		// secret rotation
+		recycled, idle := c.waitConnection(hbCtx, cancel, conn)
+		if c.cfg.FlushOnDeadline && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			if writerExited != nil {
+				c.stopWriter(writerExited)
+				writerExited = nil
+			}
+			c.deadlineFlush(conn)
+		}
+		_ = conn.Close()
+		if writerExited != nil {
+			c.stopWriter(writerExited)
+		}
+		c.setConn(nil)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if idle {
+			c.setState(StateSuspended)
+			if err := c.waitIdle(ctx); err != nil {
+				return err
+			}
+			c.setState(StateConnecting)
+			delay = c.cfg.BackoffInitial
+			continue
+		}
+		c.setState(StateReconnecting)
+		if recycled {
+			delay = c.cfg.BackoffInitial
+			continue
+		}
+		attempts++
+		if !c.shouldReconnect(ErrConnectionLost, attempts) {
+			return ErrConnectionLost
 		}
-		c.conn = nil
-		time.Sleep(delay)
-		delay = time.Duration(math.Min(float64(c.cfg.BackoffMax), float64(delay)*2))
+		if delay, err = c.sleepBackoff(ctx, delay, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// connectContext bounds dial, auth and hello by ConnectTimeout when set.
+func (c *Client) connectContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.cfg.ConnectTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.cfg.ConnectTimeout)
+}
+
+// connectErr reports err as ErrConnectTimeout when the connect budget, not
+// the caller's context, ran out.
+func connectErr(ctx, connCtx context.Context, err error) error {
+	if err == nil || ctx.Err() != nil || !errors.Is(connCtx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	if errors.Is(err, ErrConnectTimeout) {
+		return err
+	}
+	return fmt.Errorf("%w: %v", ErrConnectTimeout, err)
+}
+
+// authDeadlineErr tells a ConnectTimeout expiry apart from an auth timeout.
+func (c *Client) authDeadlineErr(ctx context.Context) error {
+	if dl, ok := ctx.Deadline(); ok && !time.Now().Before(dl) {
+		return ErrConnectTimeout
 	}
+	return ErrAuthTimeout
 }
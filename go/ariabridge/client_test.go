@@ -1,9 +1,12 @@
 package ariabridge
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"math"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
@@ -22,8 +25,14 @@ type harness struct {
 	mu       sync.Mutex
 	conns    int
 	msgs     []map[string]any
+	raw      [][]byte
 	autoPong bool
 	conn     *websocket.Conn
+	handler  func(c *websocket.Conn, m map[string]any)
+	authInfo map[string]any
+	header   http.Header
+	// skipPongs leaves that many pings unanswered
+	skipPongs int
 }
 
 func newHarness(t *testing.T, autoPong bool) *harness {
@@ -37,6 +46,7 @@ func newHarness(t *testing.T, autoPong bool) *harness {
 		h.mu.Lock()
 		h.conns++
 		h.conn = conn
+		h.header = r.Header.Clone()
 		h.mu.Unlock()
 		if !h.autoPong {
 			go func(c *websocket.Conn) {
@@ -55,12 +65,29 @@ func newHarness(t *testing.T, autoPong bool) *harness {
 				_ = json.Unmarshal(data, &m)
 				h.mu.Lock()
 				h.msgs = append(h.msgs, m)
+				h.raw = append(h.raw, data)
+				handler := h.handler
 				h.mu.Unlock()
+				if handler != nil {
+					handler(c, m)
+				}
 				switch m["type"] {
 				case "auth":
-					_ = c.WriteJSON(map[string]any{"type": "auth_success", "role": "bridge"})
+					reply := map[string]any{"type": "auth_success", "role": "bridge"}
+					h.mu.Lock()
+					for k, v := range h.authInfo {
+						reply[k] = v
+					}
+					h.mu.Unlock()
+					_ = c.WriteJSON(reply)
 				case "ping":
-					if h.autoPong {
+					h.mu.Lock()
+					skip := h.skipPongs > 0
+					if skip {
+						h.skipPongs--
+					}
+					h.mu.Unlock()
+					if h.autoPong && !skip {
 						_ = c.WriteJSON(map[string]any{"type": "pong"})
 					}
 				}
@@ -73,6 +100,12 @@ func newHarness(t *testing.T, autoPong bool) *harness {
 
 func (h *harness) close() { h.srv.Close() }
 
+func (h *harness) onMessage(fn func(c *websocket.Conn, m map[string]any)) {
+	h.mu.Lock()
+	h.handler = fn
+	h.mu.Unlock()
+}
+
 func (h *harness) sendControlRequest(t *testing.T, id string, action string) {
 	h.mu.Lock()
 	conn := h.conn
@@ -83,7 +116,30 @@ func (h *harness) sendControlRequest(t *testing.T, id string, action string) {
 	_ = conn.WriteJSON(map[string]any{"type": "control_request", "id": id, "action": action})
 }
 
+func (h *harness) sendJSON(t *testing.T, v any) {
+	h.mu.Lock()
+	conn := h.conn
+	h.mu.Unlock()
+	if conn == nil {
+		t.Fatalf("no connection")
+	}
+	_ = conn.WriteJSON(v)
+}
+
+func (h *harness) ofType(typ string) []map[string]any {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var out []map[string]any
+	for _, m := range h.msgs {
+		if m["type"] == typ {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
 func waitFor(t *testing.T, cond func() bool, timeout time.Duration) {
+	t.Helper()
 	deadline := time.Now().Add(timeout)
 	for time.Now().Before(deadline) {
 		if cond() {
@@ -219,7 +275,7 @@ func TestControlRoundTrip(t *testing.T) {
 
 func TestJitterBackoffUsed(t *testing.T) {
 	called := false
-	jitterFn = func(d time.Duration) time.Duration { called = true; return d }
+	jitterFn = func(d time.Duration, attempt int) time.Duration { called = true; return d }
 	defer func() { jitterFn = jitter }()
 
 	cfg := ClientConfig{URL: "ws://0.0.0.0:1", Secret: "dev-secret", BackoffInitial: 10 * time.Millisecond, BackoffMax: 20 * time.Millisecond}
@@ -234,3 +290,503 @@ func TestJitterBackoffUsed(t *testing.T) {
 		t.Fatalf("jitter function not invoked")
 	}
 }
+
+func TestCloseDuringBackoffReturnsPromptly(t *testing.T) {
+	jitterFn = func(d time.Duration, attempt int) time.Duration { return d }
+	defer func() { jitterFn = jitter }()
+
+	cfg := ClientConfig{URL: "ws://0.0.0.0:1", Secret: "dev-secret", BackoffInitial: 5 * time.Second, BackoffMax: 10 * time.Second}
+	c := NewClient(cfg)
+	done := make(chan error, 1)
+	go func() { done <- c.Start(context.Background()) }()
+
+	time.Sleep(50 * time.Millisecond)
+	_ = c.Close()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("start returned %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("start did not return after close")
+	}
+}
+
+func TestSendRawBufferedAndByteIdentical(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret"})
+	payload := []byte(`{"type":"console",  "level":"info","message":"pre-serialized" }`)
+	if err := c.SendRaw(payload); err != nil {
+		t.Fatalf("send raw: %v", err)
+	}
+	payload[2] = 'X' // caller reuse must not corrupt the buffered copy
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+
+	waitFor(t, func() bool { return len(h.ofType("console")) == 1 }, time.Second)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	want := `{"type":"console",  "level":"info","message":"pre-serialized" }`
+	for _, raw := range h.raw {
+		if string(raw) == want {
+			return
+		}
+	}
+	t.Fatalf("raw frame not delivered byte-identical")
+}
+
+func TestSendRawValidates(t *testing.T) {
+	c := NewClient(ClientConfig{URL: "ws://0.0.0.0:1", Secret: "dev-secret"})
+	if err := c.SendRaw([]byte(`{"message":"no type"}`)); err == nil {
+		t.Fatalf("expected missing type error")
+	}
+	if err := c.SendRaw([]byte(`not json`)); err == nil {
+		t.Fatalf("expected invalid json error")
+	}
+}
+
+func TestStartTwiceReturnsErrAlreadyStarted(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+
+	waitFor(t, func() bool { return len(h.ofType("hello")) == 1 }, time.Second)
+	if err := c.Start(ctx); !errors.Is(err, ErrAlreadyStarted) {
+		t.Fatalf("second start returned %v", err)
+	}
+}
+
+func TestStartAfterCloseReturnsErrClosed(t *testing.T) {
+	c := NewClient(ClientConfig{URL: "ws://0.0.0.0:1", Secret: "dev-secret"})
+	_ = c.Close()
+	if err := c.Start(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Fatalf("start after close returned %v", err)
+	}
+}
+
+func TestEventIDsUniqueAcrossReconnect(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", BackoffInitial: 10 * time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+
+	waitFor(t, func() bool { return len(h.ofType("hello")) == 1 }, time.Second)
+	for i := 0; i < 200; i++ {
+		_ = c.SendConsole("info", "before")
+	}
+	waitFor(t, func() bool { return len(h.ofType("console")) == 200 }, 2*time.Second)
+	h.mu.Lock()
+	_ = h.conn.Close()
+	h.mu.Unlock()
+	waitFor(t, func() bool { return len(h.ofType("hello")) == 2 }, 2*time.Second)
+	for i := 0; i < 200; i++ {
+		_ = c.SendConsole("info", "after")
+	}
+
+	waitFor(t, func() bool { return len(h.ofType("console")) == 400 }, 2*time.Second)
+	seen := map[string]bool{}
+	for _, m := range h.ofType("console") {
+		id, _ := m["eventId"].(string)
+		if id == "" || seen[id] {
+			t.Fatalf("missing or duplicate eventId %q", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestCustomIDGenerator(t *testing.T) {
+	c := NewClient(ClientConfig{URL: "ws://0.0.0.0:1", Secret: "dev-secret", IDGenerator: func() string { return "fixed" }})
+	_ = c.SendConsole("info", "x")
+	if got := c.buffer.drain()[0].ev["eventId"]; got != "fixed" {
+		t.Fatalf("eventId %v", got)
+	}
+}
+
+func TestInfoCapabilityAdvertisedByDefault(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+
+	waitFor(t, func() bool { return len(h.ofType("hello")) == 1 }, time.Second)
+	caps := h.ofType("hello")[0]["capabilities"].([]any)
+	for _, cp := range caps {
+		if cp == "info" {
+			return
+		}
+	}
+	t.Fatalf("info capability missing from %v", caps)
+}
+
+func TestDropNoticeRequiresInfoCapability(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", BufferLimit: 1, Capabilities: []string{"console"}})
+	_ = c.SendConsole("info", "m0")
+	_ = c.SendConsole("info", "m1")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+
+	waitFor(t, func() bool { return len(h.ofType("console")) == 1 }, time.Second)
+	_ = c.SendConsole("info", "m2")
+	waitFor(t, func() bool { return len(h.ofType("console")) == 2 }, time.Second)
+	if n := len(h.ofType("info")); n != 0 {
+		t.Fatalf("drop notice sent without info capability")
+	}
+}
+
+func TestCloseReportsFailedFinalFlush(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	c.bufMu.Lock()
+	c.buffer.push(queued{ev: map[string]any{"type": "console", "message": "stranded"}})
+	_ = c.conn.UnderlyingConn().Close()
+	c.bufMu.Unlock()
+
+	err := c.Close()
+	if err == nil || !strings.Contains(err.Error(), "final flush") {
+		t.Fatalf("close returned %v", err)
+	}
+}
+
+func TestCloseCleanReturnsNil(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret"})
+	go c.Start(context.Background())
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+	if err := c.Close(); err != nil {
+		t.Fatalf("close returned %v", err)
+	}
+}
+
+func TestCustomMarshalUsedForSends(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	calls := 0
+	var mu sync.Mutex
+	marshal := func(v any) ([]byte, error) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(v); err != nil {
+			return nil, err
+		}
+		return bytes.TrimRight(buf.Bytes(), "\n"), nil
+	}
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", Marshal: marshal})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+	_ = c.SendConsole("info", "<b>&</b>")
+	waitFor(t, func() bool { return len(h.ofType("console")) == 1 }, time.Second)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	found := false
+	for _, raw := range h.raw {
+		if bytes.Contains(raw, []byte(`"<b>&</b>"`)) {
+			found = true
+		}
+		if bytes.Contains(raw, []byte(`\u003c`)) {
+			t.Fatalf("default HTML escaping applied: %s", raw)
+		}
+	}
+	if !found {
+		t.Fatalf("unescaped message not found")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if calls < 3 {
+		t.Fatalf("custom marshal called %d times", calls)
+	}
+}
+
+func TestMaxConnectionAgeRecyclesConnection(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", MaxConnectionAge: 80 * time.Millisecond, BackoffInitial: time.Minute})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- c.Start(ctx) }()
+
+	waitFor(t, func() bool { return len(h.ofType("hello")) >= 3 }, 2*time.Second)
+	select {
+	case err := <-done:
+		t.Fatalf("start returned %v", err)
+	default:
+	}
+}
+
+func TestSecretHeaderNameConfigurable(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", SecretHeaderName: "X-Api-Key"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if got := h.header.Get("X-Api-Key"); got != "dev-secret" {
+		t.Fatalf("X-Api-Key = %q", got)
+	}
+	if got := h.header.Get("X-Bridge-Secret"); got != "" {
+		t.Fatalf("default header still sent: %q", got)
+	}
+}
+
+func TestSendConsoleLinesUseSendPipeline(t *testing.T) {
+	router := func(level string) (string, string) { return "log", "" }
+	c := NewClient(ClientConfig{URL: "ws://0.0.0.0:1", Secret: "dev-secret", LevelRouter: router, MaxEventBytes: 200})
+	long := strings.Repeat("x", 300)
+	if err := c.SendConsoleLines("info", []string{"ok", long}); !errors.Is(err, ErrEventTooLarge) {
+		t.Fatalf("oversized line returned %v", err)
+	}
+	if err := c.SendConsoleLines("info", []string{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	qs := c.buffer.drain()
+	if len(qs) != 2 {
+		t.Fatalf("buffered %d lines", len(qs))
+	}
+	for _, q := range qs {
+		if q.ev["type"] != "log" || q.ev["order"] == nil {
+			t.Fatalf("line not routed and stamped: %v", q.ev)
+		}
+	}
+}
+
+func TestSendConsoleLinesContiguous(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	lines := make([]string, 50)
+	for i := range lines {
+		lines[i] = "line " + itoa(i)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			_ = c.SendConsole("info", "other")
+		}
+	}()
+	if err := c.SendConsoleLines("info", lines); err != nil {
+		t.Fatalf("send lines: %v", err)
+	}
+	<-done
+
+	waitFor(t, func() bool { return len(h.ofType("console")) == 100 }, time.Second)
+	var got []string
+	for _, m := range h.ofType("console") {
+		if m["message"] != "other" {
+			got = append(got, m["message"].(string))
+		} else if len(got) > 0 && len(got) < len(lines) {
+			t.Fatalf("lines interleaved with other events after %d lines", len(got))
+		}
+		if _, ok := m["timestamp"].(float64); !ok {
+			t.Fatalf("missing timestamp: %v", m)
+		}
+	}
+	for i, l := range got {
+		if l != lines[i] {
+			t.Fatalf("line %d = %q", i, l)
+		}
+	}
+}
+
+func TestJitterSpreadGrowsWithAttempts(t *testing.T) {
+	const samples = 2000
+	base := 100 * time.Millisecond
+	spread := func(attempt int) time.Duration {
+		lo, hi := time.Duration(math.MaxInt64), time.Duration(0)
+		for i := 0; i < samples; i++ {
+			d := jitter(base, attempt)
+			if d > base || d < base/2 {
+				t.Fatalf("attempt %d jitter %v outside %v-%v", attempt, d, base/2, base)
+			}
+			if d < lo {
+				lo = d
+			}
+			if d > hi {
+				hi = d
+			}
+		}
+		return hi - lo
+	}
+	prev := time.Duration(0)
+	for attempt := 1; attempt <= 4; attempt++ {
+		s := spread(attempt)
+		want := time.Duration(float64(base) * 0.5 * float64(attempt) / float64(attempt+1))
+		if s < want*9/10 || s > want {
+			t.Fatalf("attempt %d spread %v, want about %v", attempt, s, want)
+		}
+		if s <= prev {
+			t.Fatalf("attempt %d spread %v did not grow past %v", attempt, s, prev)
+		}
+		prev = s
+	}
+}
+
+func TestSaturatedBackoffStillVaries(t *testing.T) {
+	const limit = 40 * time.Millisecond
+	c := NewClient(ClientConfig{URL: "ws://0.0.0.0:1", BackoffMax: limit})
+	lo, hi := time.Duration(math.MaxInt64), time.Duration(0)
+	for i := 0; i < 20; i++ {
+		start := time.Now()
+		next, err := c.backoffSleep(context.Background(), time.Second, 5)
+		slept := time.Since(start)
+		if err != nil || next != limit {
+			t.Fatalf("next delay %v, err %v", next, err)
+		}
+		if slept < limit/2 {
+			t.Fatalf("slept %v, below half of BackoffMax", slept)
+		}
+		lo, hi = min(lo, slept), max(hi, slept)
+	}
+	if hi-lo < limit/8 {
+		t.Fatalf("saturated delays only spread over %v", hi-lo)
+	}
+}
+
+func TestResetBackoffShortensPendingRetry(t *testing.T) {
+	jitterFn = func(d time.Duration, attempt int) time.Duration {
+		if attempt >= 2 {
+			return 5 * time.Second
+		}
+		return d
+	}
+	defer func() { jitterFn = jitter }()
+
+	dials := make(chan time.Time, 8)
+	cfg := ClientConfig{
+		URL:            "ws://127.0.0.1:1",
+		Secret:         "dev-secret",
+		BackoffInitial: 10 * time.Millisecond,
+		BackoffMax:     10 * time.Second,
+		NetDial: func(ctx context.Context) (net.Conn, error) {
+			dials <- time.Now()
+			return nil, errors.New("refused")
+		},
+	}
+	c := NewClient(cfg)
+	ctx, cancel := context.WithCancel(context.Background())
+	// stop the client before jitterFn is restored
+	done := make(chan struct{})
+	defer func() { cancel(); <-done }()
+	go func() {
+		_ = c.Start(ctx)
+		close(done)
+	}()
+
+	<-dials
+	<-dials
+	time.Sleep(50 * time.Millisecond)
+	reset := time.Now()
+	c.ResetBackoff()
+	select {
+	case at := <-dials:
+		if at.Sub(reset) > time.Second {
+			t.Fatalf("retry %v after reset", at.Sub(reset))
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("no retry after ResetBackoff")
+	}
+}
+
+func TestShouldReconnectVetoStopsStart(t *testing.T) {
+	dialErr := errors.New("refused")
+	var dials int
+	var seen []int
+	cfg := ClientConfig{
+		URL:            "ws://127.0.0.1:1",
+		Secret:         "dev-secret",
+		BackoffInitial: time.Millisecond,
+		NetDial: func(ctx context.Context) (net.Conn, error) {
+			dials++
+			return nil, dialErr
+		},
+		ShouldReconnect: func(err error, attempt int) bool {
+			seen = append(seen, attempt)
+			return attempt < 2
+		},
+	}
+	done := make(chan error, 1)
+	go func() { done <- NewClient(cfg).Start(context.Background()) }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, dialErr) {
+			t.Fatalf("start returned %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("start kept reconnecting")
+	}
+	if dials != 2 || !reflect.DeepEqual(seen, []int{1, 2}) {
+		t.Fatalf("%d dials, attempts %v", dials, seen)
+	}
+}
+
+func TestShouldReconnectVetoAfterDrop(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	c := NewClient(ClientConfig{
+		URL:             h.url,
+		Secret:          "dev-secret",
+		ShouldReconnect: func(error, int) bool { return false },
+	})
+	done := make(chan error, 1)
+	go func() { done <- c.Start(context.Background()) }()
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+	h.mu.Lock()
+	_ = h.conn.Close()
+	h.mu.Unlock()
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrConnectionLost) {
+			t.Fatalf("start returned %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("start reconnected despite veto")
+	}
+}
@@ -0,0 +1,11 @@
+package ariabridge
+
+import "time"
+
+var monoStart = time.Now()
+
+// monoNow is the time since process start on the monotonic clock, which wall
+// clock steps never move.
+func monoNow() time.Duration {
+	return time.Since(monoStart)
+}
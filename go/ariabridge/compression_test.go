@@ -0,0 +1,96 @@
+package ariabridge
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// frameRecorder notes the RSV1 (compressed) bit of every text frame the
+// client writes. Client frames reach the socket one Write per frame.
+type frameRecorder struct {
+	net.Conn
+	mu     sync.Mutex
+	frames []bool
+}
+
+func (f *frameRecorder) Write(p []byte) (int, error) {
+	if len(p) > 0 && p[0]&0x0f == websocket.TextMessage {
+		f.mu.Lock()
+		f.frames = append(f.frames, p[0]&0x40 != 0)
+		f.mu.Unlock()
+	}
+	return f.Conn.Write(p)
+}
+
+func (f *frameRecorder) compressed() (on, off int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range f.frames {
+		if c {
+			on++
+		} else {
+			off++
+		}
+	}
+	return on, off
+}
+
+func TestCompressionThreshold(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	up := websocket.Upgrader{EnableCompression: true}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := up.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			received = append(received, string(data))
+			mu.Unlock()
+			if strings.Contains(string(data), `"type":"auth"`) {
+				_ = conn.WriteJSON(map[string]any{"type": "auth_success"})
+			}
+		}
+	}))
+	defer srv.Close()
+
+	rec := &frameRecorder{}
+	c := NewClient(ClientConfig{
+		URL:                  "ws" + srv.URL[4:],
+		Secret:               "dev-secret",
+		EnableCompression:    true,
+		CompressionThreshold: 1024,
+		NetDial: func(ctx context.Context) (net.Conn, error) {
+			nc, err := (&net.Dialer{}).DialContext(ctx, "tcp", srv.Listener.Addr().String())
+			rec.Conn = nc
+			return rec, err
+		},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	_ = c.SendConsole("info", "small")
+	_ = c.SendConsole("info", strings.Repeat("large ", 1000))
+	waitFor(t, func() bool { mu.Lock(); defer mu.Unlock(); return len(received) == 4 }, time.Second)
+
+	on, off := rec.compressed()
+	if on != 1 || off != 3 {
+		t.Fatalf("compressed frames %d, uncompressed %d", on, off)
+	}
+}
@@ -0,0 +1,207 @@
+package ariabridge
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// pipeListener hands pre-made connections to an http.Server.
+type pipeListener struct {
+	conns chan net.Conn
+	once  sync.Once
+	done  chan struct{}
+}
+
+func newPipeListener() *pipeListener {
+	return &pipeListener{conns: make(chan net.Conn, 4), done: make(chan struct{})}
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *pipeListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr { return &net.TCPAddr{} }
+
+func TestStartWithConnOverPipe(t *testing.T) {
+	ln := newPipeListener()
+	msgs := make(chan map[string]any, 16)
+	up := websocket.Upgrader{}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := up.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			var m map[string]any
+			if err := conn.ReadJSON(&m); err != nil {
+				return
+			}
+			msgs <- m
+			if m["type"] == "auth" {
+				_ = conn.WriteJSON(map[string]any{"type": "auth_success", "role": "bridge"})
+			}
+		}
+	})}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	clientSide, serverSide := net.Pipe()
+	ln.conns <- serverSide
+
+	c := NewClient(ClientConfig{URL: "ws://bridge.pipe/", Secret: "dev-secret"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.StartWithConn(ctx, clientSide)
+
+	var types []any
+	timeout := time.After(2 * time.Second)
+	for len(types) < 2 {
+		select {
+		case m := <-msgs:
+			types = append(types, m["type"])
+		case <-timeout:
+			t.Fatalf("handshake over pipe incomplete: %v", types)
+		}
+	}
+	if types[0] != "auth" || types[1] != "hello" {
+		t.Fatalf("messages %v", types)
+	}
+}
+
+func TestNetDialFactoryUsedForReconnect(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	var mu sync.Mutex
+	dials := 0
+	c := NewClient(ClientConfig{
+		URL:            h.url,
+		Secret:         "dev-secret",
+		BackoffInitial: 10 * time.Millisecond,
+		NetDial: func(ctx context.Context) (net.Conn, error) {
+			mu.Lock()
+			dials++
+			mu.Unlock()
+			var d net.Dialer
+			return d.DialContext(ctx, "tcp", h.srv.Listener.Addr().String())
+		},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+
+	waitFor(t, func() bool { return len(h.ofType("hello")) == 1 }, time.Second)
+	h.mu.Lock()
+	_ = h.conn.Close()
+	h.mu.Unlock()
+	waitFor(t, func() bool { return len(h.ofType("hello")) == 2 }, 2*time.Second)
+	mu.Lock()
+	defer mu.Unlock()
+	if dials != 2 {
+		t.Fatalf("factory dialed %d times", dials)
+	}
+}
+
+func TestHandshakeResponseValidatorRejects(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{
+		URL:            h.url,
+		Secret:         "dev-secret",
+		BackoffInitial: 5 * time.Millisecond,
+		BackoffMax:     10 * time.Millisecond,
+		ValidateHandshakeResponse: func(resp *http.Response) error {
+			if resp.Header.Get("X-Bridge-Server-Version") == "" {
+				return errors.New("missing server version")
+			}
+			return nil
+		},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+
+	waitFor(t, func() bool { h.mu.Lock(); defer h.mu.Unlock(); return h.conns >= 3 }, time.Second)
+	if n := len(h.ofType("auth")); n != 0 {
+		t.Fatalf("client sent auth to a rejected server %d times", n)
+	}
+	if s := c.State(); s != StateConnecting {
+		t.Fatalf("state %s", s)
+	}
+}
+
+func TestFailFastOnFirstDial(t *testing.T) {
+	dialErr := errors.New("connection refused")
+	var dials atomic.Int32
+	c := NewClient(ClientConfig{
+		URL:                 "ws://127.0.0.1:1",
+		Secret:              "dev-secret",
+		BackoffInitial:      5 * time.Millisecond,
+		FailFastOnFirstDial: true,
+		NetDial: func(ctx context.Context) (net.Conn, error) {
+			dials.Add(1)
+			return nil, dialErr
+		},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := c.Start(ctx); !errors.Is(err, dialErr) {
+		t.Fatalf("expected dial error, got %v", err)
+	}
+	if n := dials.Load(); n != 1 {
+		t.Fatalf("dialed %d times", n)
+	}
+}
+
+func TestSendAfterReaderExitIsBuffered(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", BackoffInitial: 10 * time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	// the reader has seen the socket die but run has not cleared conn yet
+	c.bufMu.Lock()
+	c.alive.Store(false)
+	c.bufMu.Unlock()
+	if err := c.SendConsole("info", "during teardown"); err != nil {
+		t.Fatal(err)
+	}
+	c.bufMu.Lock()
+	buffered := c.buffer.len()
+	c.bufMu.Unlock()
+	if buffered != 1 {
+		t.Fatalf("buffered %d events", buffered)
+	}
+
+	h.mu.Lock()
+	_ = h.conn.Close()
+	h.mu.Unlock()
+	waitFor(t, func() bool { return consoleMessages(h)["during teardown"] }, 2*time.Second)
+	if n := len(h.ofType("hello")); n != 2 {
+		t.Fatalf("delivered after %d hellos", n)
+	}
+}
@@ -0,0 +1,122 @@
+package ariabridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const controlDedupWindowDefault = time.Minute
+
+var (
+	ErrControlResultTooLarge = errors.New("control result too large")
+	ErrShuttingDown          = errors.New("client shutting down")
+)
+
+type controlEntry struct {
+	resp map[string]any
+	at   time.Time
+}
+
+// cachedControl returns the result already sent for id within
+// ControlDedupWindow, pruning expired entries as it goes.
+func (c *Client) cachedControl(id string) (map[string]any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for k, e := range c.controlSeen {
+		if now.Sub(e.at) > c.cfg.ControlDedupWindow {
+			delete(c.controlSeen, k)
+		}
+	}
+	e, ok := c.controlSeen[id]
+	if !ok {
+		return nil, false
+	}
+	return copyEvent(e.resp), true
+}
+
+func (c *Client) rememberControl(id string, resp map[string]any) {
+	if c.cfg.ControlDedupWindow < 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.controlSeen == nil {
+		c.controlSeen = map[string]controlEntry{}
+	}
+	c.controlSeen[id] = controlEntry{resp: copyEvent(resp), at: time.Now()}
+}
+
+// checkControlResult rejects results whose encoding exceeds
+// MaxControlResultBytes, so an oversized reply cannot get the connection
+// closed by the server.
+func (c *Client) checkControlResult(result any) error {
+	if c.cfg.MaxControlResultBytes <= 0 {
+		return nil
+	}
+	data, err := c.cfg.Marshal(result)
+	if err != nil {
+		return err
+	}
+	if len(data) > c.cfg.MaxControlResultBytes {
+		return fmt.Errorf("%w: %d bytes exceeds limit of %d", ErrControlResultTooLarge, len(data), c.cfg.MaxControlResultBytes)
+	}
+	return nil
+}
+
+// beginControl registers a running handler unless graceful shutdown has
+// started. Registration and the shutdown flag share c.mu so no handler can
+// start once waitControls is waiting.
+func (c *Client) beginControl() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.shuttingDown {
+		return false
+	}
+	c.controls.Add(1)
+	return true
+}
+
+// waitControls refuses new control requests and waits for running handlers
+// until ctx is done.
+func (c *Client) waitControls(ctx context.Context) error {
+	c.mu.Lock()
+	c.shuttingDown = true
+	c.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		c.controls.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func controlID(msg map[string]any) string {
+	if msg["id"] == nil {
+		return ""
+	}
+	return fmt.Sprint(msg["id"])
+}
+
+func copyEvent(ev map[string]any) map[string]any {
+	out := make(map[string]any, len(ev))
+	for k, v := range ev {
+		out[k] = v
+	}
+	return out
+}
+
+// OnControlScoped is like OnControl but also hands the handler a child client
+// whose events carry the request id as correlationId.
+func (c *Client) OnControlScoped(handler func(child *Client, msg map[string]any) (any, error)) {
+	c.OnControl(func(msg map[string]any) (any, error) {
+		return handler(c.WithFields(map[string]any{"correlationId": msg["id"]}), msg)
+	})
+}
@@ -0,0 +1,123 @@
+package ariabridge
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDuplicateControlRequestReplaysResult(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret"})
+	var calls atomic.Int32
+	c.OnControl(func(msg map[string]any) (any, error) {
+		return map[string]any{"call": calls.Add(1)}, nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	h.sendControlRequest(t, "dup-1", "reload")
+	h.sendControlRequest(t, "dup-1", "reload")
+
+	waitFor(t, func() bool { return len(h.ofType("control_result")) == 2 }, time.Second)
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("handler ran %d times", n)
+	}
+	results := h.ofType("control_result")
+	for _, m := range results {
+		if m["id"] != "dup-1" || m["ok"] != true || m["result"].(map[string]any)["call"] != float64(1) {
+			t.Fatalf("result %v", m)
+		}
+	}
+}
+
+func TestControlDedupWindowExpires(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", ControlDedupWindow: 20 * time.Millisecond})
+	var calls atomic.Int32
+	c.OnControl(func(msg map[string]any) (any, error) { calls.Add(1); return nil, nil })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	h.sendControlRequest(t, "c1", "reload")
+	waitFor(t, func() bool { return len(h.ofType("control_result")) == 1 }, time.Second)
+	time.Sleep(40 * time.Millisecond)
+	h.sendControlRequest(t, "c1", "reload")
+	waitFor(t, func() bool { return len(h.ofType("control_result")) == 2 }, time.Second)
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("handler ran %d times after window", n)
+	}
+}
+
+func TestOversizedControlResultReplacedWithError(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", MaxControlResultBytes: 64})
+	c.OnControl(func(msg map[string]any) (any, error) {
+		if msg["action"] == "big" {
+			return strings.Repeat("x", 1000), nil
+		}
+		return "small", nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	h.sendControlRequest(t, "c1", "big")
+	h.sendControlRequest(t, "c2", "small")
+	waitFor(t, func() bool { return len(h.ofType("control_result")) == 2 }, time.Second)
+
+	for _, m := range h.ofType("control_result") {
+		switch m["id"] {
+		case "c1":
+			msg, _ := m["error"].(map[string]any)["message"].(string)
+			if m["ok"] != false || m["result"] != nil || !strings.Contains(msg, "too large") {
+				t.Fatalf("oversized result %v", m)
+			}
+		case "c2":
+			if m["ok"] != true || m["result"] != "small" {
+				t.Fatalf("small result %v", m)
+			}
+		}
+	}
+}
+
+func TestScopedControlHandlerCorrelatesEvents(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret"})
+	c.OnControlScoped(func(child *Client, msg map[string]any) (any, error) {
+		_ = child.SendConsole("info", "working on "+msg["action"].(string))
+		return "done", nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	h.sendControlRequest(t, "req-7", "reload")
+	_ = c.SendConsole("info", "unrelated")
+	waitFor(t, func() bool { return len(h.ofType("console")) == 2 && len(h.ofType("control_result")) == 1 }, time.Second)
+
+	for _, m := range h.ofType("console") {
+		want := any(nil)
+		if m["message"] == "working on reload" {
+			want = "req-7"
+		}
+		if m["correlationId"] != want {
+			t.Fatalf("event %v has correlationId %v", m["message"], m["correlationId"])
+		}
+	}
+	if res := h.ofType("control_result")[0]; res["correlationId"] != nil {
+		t.Fatalf("control result carries correlationId: %v", res)
+	}
+}
@@ -0,0 +1,54 @@
+package ariabridge
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ReconnectCoordinator is a token bucket shared by clients in one process so
+// that their connection attempts after a server outage are spread out instead
+// of arriving together.
+type ReconnectCoordinator struct {
+	mu       sync.Mutex
+	interval time.Duration
+	burst    float64
+	tokens   float64
+	last     time.Time
+}
+
+// NewReconnectCoordinator allows one connection attempt per interval, with up
+// to burst attempts let through at once.
+func NewReconnectCoordinator(interval time.Duration, burst int) *ReconnectCoordinator {
+	if burst < 1 {
+		burst = 1
+	}
+	return &ReconnectCoordinator{interval: interval, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// Wait blocks until an attempt may proceed or ctx is done.
+func (r *ReconnectCoordinator) Wait(ctx context.Context) error {
+	for {
+		r.mu.Lock()
+		now := time.Now()
+		if r.interval > 0 {
+			r.tokens += float64(now.Sub(r.last)) / float64(r.interval)
+		} else {
+			r.tokens = r.burst
+		}
+		if r.tokens > r.burst {
+			r.tokens = r.burst
+		}
+		r.last = now
+		if r.tokens >= 1 {
+			r.tokens--
+			r.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - r.tokens) * float64(r.interval))
+		r.mu.Unlock()
+		if err := sleepCtx(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
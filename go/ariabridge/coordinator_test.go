@@ -0,0 +1,88 @@
+package ariabridge
+
+import (
+	"context"
+	"net"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReconnectCoordinatorStaggersClients(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	addr := h.srv.Listener.Addr().String()
+
+	const clients = 5
+	const interval = 30 * time.Millisecond
+	rc := NewReconnectCoordinator(interval, 1)
+
+	var mu sync.Mutex
+	var live []net.Conn
+	var dials []time.Time
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var cs []*Client
+	for i := 0; i < clients; i++ {
+		c := NewClient(ClientConfig{
+			URL:                  h.url,
+			Secret:               "dev-secret",
+			BackoffInitial:       time.Millisecond,
+			BackoffMax:           time.Millisecond,
+			ReconnectCoordinator: rc,
+			NetDial: func(ctx context.Context) (net.Conn, error) {
+				nc, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+				mu.Lock()
+				dials = append(dials, time.Now())
+				if err == nil {
+					live = append(live, nc)
+				}
+				mu.Unlock()
+				return nc, err
+			},
+		})
+		cs = append(cs, c)
+		go c.Start(ctx)
+	}
+	connected := func() bool {
+		for _, c := range cs {
+			if c.State() != StateConnected {
+				return false
+			}
+		}
+		return true
+	}
+	waitFor(t, connected, 2*time.Second)
+
+	// drop every connection at once, as a server blip would
+	mu.Lock()
+	for _, nc := range live {
+		_ = nc.Close()
+	}
+	live, dials = nil, nil
+	mu.Unlock()
+	waitFor(t, func() bool { mu.Lock(); defer mu.Unlock(); return len(dials) == clients }, 2*time.Second)
+	waitFor(t, connected, 2*time.Second)
+
+	mu.Lock()
+	defer mu.Unlock()
+	sort.Slice(dials, func(i, j int) bool { return dials[i].Before(dials[j]) })
+	for i := 1; i < len(dials); i++ {
+		if gap := dials[i].Sub(dials[i-1]); gap < interval*2/3 {
+			t.Fatalf("reconnects %d and %d only %v apart", i-1, i, gap)
+		}
+	}
+}
+
+func TestReconnectCoordinatorHonorsContext(t *testing.T) {
+	rc := NewReconnectCoordinator(time.Hour, 1)
+	if err := rc.Wait(context.Background()); err != nil {
+		t.Fatalf("first wait: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := rc.Wait(ctx); err == nil {
+		t.Fatalf("second wait should block until the context ends")
+	}
+}
@@ -0,0 +1,27 @@
+// Package ariabridge is the Go client for an Aria bridge host. It streams
+// console, metric and custom events over a websocket, buffering them while
+// the connection is down, and answers the host's control requests.
+//
+// The package depends only on gorilla/websocket, so SendMetricsSnapshot takes
+// its own Gatherer rather than a prometheus.Gatherer. The snapshot is sent as
+// {"type": "metrics", "format": "openmetrics", "payload": ...}. A registry is
+// adapted with expfmt:
+//
+//	g := ariabridge.GathererFunc(func(w io.Writer) error {
+//		mfs, err := reg.Gather()
+//		if err != nil {
+//			return err
+//		}
+//		enc := expfmt.NewEncoder(w, expfmt.NewFormat(expfmt.TypeOpenMetrics))
+//		for _, mf := range mfs {
+//			if err := enc.Encode(mf); err != nil {
+//				return err
+//			}
+//		}
+//		if closer, ok := enc.(expfmt.Closer); ok {
+//			return closer.Close()
+//		}
+//		return nil
+//	})
+//	err := client.SendMetricsSnapshot(g)
+package ariabridge
@@ -0,0 +1,200 @@
+package ariabridge
+
+import (
+	"errors"
+	"reflect"
+)
+
+// EvictionPolicy decides what stays buffered when an event arrives at a full
+// buffer. It returns the events to keep, in order, and how many were dropped.
+type EvictionPolicy interface {
+	Evict(buffer []map[string]any, incoming map[string]any) (keep []map[string]any, dropped int)
+}
+
+// ringEvictor is implemented by the built-in policies to evict in place on
+// the ring, without the copy and rebuild a custom EvictionPolicy needs. It
+// returns how many events were dropped.
+type ringEvictor interface {
+	evictRing(c *Client, r *ring, q queued) int
+}
+
+var (
+	// EvictOldest drops the oldest buffered event, which is also what happens
+	// when Eviction is nil.
+	EvictOldest EvictionPolicy = oldestFirst{}
+	// EvictNewest keeps the buffer as is and drops the incoming event.
+	EvictNewest EvictionPolicy = newestFirst{}
+)
+
+type oldestFirst struct{}
+
+func (oldestFirst) Evict(buffer []map[string]any, incoming map[string]any) ([]map[string]any, int) {
+	if len(buffer) == 0 {
+		return []map[string]any{incoming}, 0
+	}
+	return append(buffer[1:], incoming), 1
+}
+
+func (oldestFirst) evictRing(c *Client, r *ring, q queued) int {
+	return dropOldest(c, r, q, 1)
+}
+
+type newestFirst struct{}
+
+func (newestFirst) Evict(buffer []map[string]any, incoming map[string]any) ([]map[string]any, int) {
+	return buffer, 1
+}
+
+func (newestFirst) evictRing(c *Client, r *ring, q queued) int {
+	c.fallbackDropped(q.ev)
+	return 1
+}
+
+// dropOldest removes up to n events from the head of a full ring and appends
+// q. A zero-capacity ring drops q instead.
+func dropOldest(c *Client, r *ring, q queued, n int) int {
+	if r.cap() == 0 {
+		c.fallbackDropped(q.ev)
+		return 1
+	}
+	dropped := 0
+	for ; dropped < n; dropped++ {
+		victim, ok := r.pop()
+		if !ok {
+			break
+		}
+		c.fallbackDropped(victim.ev)
+	}
+	r.push(q)
+	return dropped
+}
+
+type oldestBatch struct {
+	fraction float64
+}
+
+// EvictOldestBatch drops the oldest fraction of the buffer, at least one
+// event, each time it fills, so a burst of overflow loses a contiguous run of
+// old events rather than one per arrival. It runs in place on the buffer
+// and costs about the same per event as the default ring overwrite; what it
+// changes is which events are lost, not the cost.
+func EvictOldestBatch(fraction float64) EvictionPolicy {
+	return oldestBatch{fraction: fraction}
+}
+
+func (p oldestBatch) Evict(buffer []map[string]any, incoming map[string]any) ([]map[string]any, int) {
+	n := p.count(len(buffer))
+	return append(buffer[n:], incoming), n
+}
+
+func (p oldestBatch) evictRing(c *Client, r *ring, q queued) int {
+	return dropOldest(c, r, q, p.count(r.len()))
+}
+
+func (p oldestBatch) count(buffered int) int {
+	n := int(float64(buffered) * p.fraction)
+	if n < 1 {
+		n = 1
+	}
+	if n > buffered {
+		n = buffered
+	}
+	return n
+}
+
+type byPriority struct {
+	priority func(map[string]any) int
+}
+
+// EvictByPriority drops the lowest-priority event, the oldest one on ties.
+// Events sent with SendWithPriority use their explicit priority instead of
+// the priority function.
+func EvictByPriority(priority func(map[string]any) int) EvictionPolicy {
+	return byPriority{priority: priority}
+}
+
+func (p byPriority) Evict(buffer []map[string]any, incoming map[string]any) ([]map[string]any, int) {
+	all := append(buffer, incoming)
+	victim := 0
+	for i := 1; i < len(all); i++ {
+		if p.of(all[i]) < p.of(all[victim]) {
+			victim = i
+		}
+	}
+	return append(all[:victim], all[victim+1:]...), 1
+}
+
+func (p byPriority) evictRing(c *Client, r *ring, q queued) int {
+	victim, lowest := r.len(), p.of(q.ev)
+	for i := r.len() - 1; i >= 0; i-- {
+		if pr := p.of(r.at(i).ev); pr <= lowest {
+			victim, lowest = i, pr
+		}
+	}
+	if victim == r.len() {
+		c.fallbackDropped(q.ev)
+		return 1
+	}
+	c.fallbackDropped(r.removeAt(victim).ev)
+	r.push(q)
+	return 1
+}
+
+func (p byPriority) of(ev map[string]any) int {
+	switch v := ev["priority"].(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	}
+	return p.priority(ev)
+}
+
+// SendWithPriority enqueues a copy of ev carrying an explicit "priority"
+// field, which EvictByPriority ranks by in place of its priority function.
+func (c *Client) SendWithPriority(ev map[string]any, priority int) error {
+	if t, _ := ev["type"].(string); t == "" {
+		return errors.New("event missing type")
+	}
+	ev = copyEvent(ev)
+	ev["priority"] = priority
+	return c.enqueue(ev)
+}
+
+// evict hands a full ring to the configured policy. Built-in policies work
+// on the ring in place; a custom policy gets the buffered events and the
+// ring is rebuilt from what it keeps. Entries are matched back by map
+// identity so raw bytes and sequence numbers survive.
+func (c *Client) evict(r *ring, q queued) int {
+	if e, ok := c.cfg.Eviction.(ringEvictor); ok {
+		return e.evictRing(c, r, q)
+	}
+	entries := append(r.drain(), q)
+	byMap := make(map[uintptr]queued, len(entries))
+	maps := make([]map[string]any, 0, len(entries)-1)
+	for i, e := range entries {
+		byMap[reflect.ValueOf(e.ev).Pointer()] = e
+		if i < len(entries)-1 {
+			maps = append(maps, e.ev)
+		}
+	}
+	keep, dropped := c.cfg.Eviction.Evict(maps, q.ev)
+	for _, m := range keep {
+		p := reflect.ValueOf(m).Pointer()
+		e, ok := byMap[p]
+		if !ok {
+			e = queued{ev: m}
+			c.buffer.stamp(&e)
+		}
+		delete(byMap, p)
+		if r.push(e) {
+			dropped++
+		}
+	}
+	for _, e := range entries {
+		if _, ok := byMap[reflect.ValueOf(e.ev).Pointer()]; ok {
+			c.fallbackDropped(e.ev)
+		}
+	}
+	return dropped
+}
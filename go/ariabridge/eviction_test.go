@@ -0,0 +1,147 @@
+package ariabridge
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func bufferedMessages(c *Client) []any {
+	return ringMessages(c.buffer.drain())
+}
+
+func fillOffline(c *Client, levels ...string) {
+	for i, level := range levels {
+		_ = c.SendConsole(level, "m"+itoa(i))
+	}
+}
+
+func TestEvictOldestMatchesDefault(t *testing.T) {
+	c := NewClient(ClientConfig{URL: "ws://0.0.0.0:1", BufferLimit: 2, Eviction: EvictOldest})
+	fillOffline(c, "info", "info", "info")
+	if got := bufferedMessages(c); !reflect.DeepEqual([]any{"m1", "m2"}, got) {
+		t.Fatalf("buffered %v", got)
+	}
+	if c.dropped != 1 {
+		t.Fatalf("dropped %d", c.dropped)
+	}
+}
+
+func TestEvictNewestKeepsBuffer(t *testing.T) {
+	c := NewClient(ClientConfig{URL: "ws://0.0.0.0:1", BufferLimit: 2, Eviction: EvictNewest})
+	fillOffline(c, "info", "info", "info", "info")
+	if got := bufferedMessages(c); !reflect.DeepEqual([]any{"m0", "m1"}, got) {
+		t.Fatalf("buffered %v", got)
+	}
+	if c.dropped != 2 {
+		t.Fatalf("dropped %d", c.dropped)
+	}
+}
+
+func TestEvictOldestBatchDropsFraction(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", BufferLimit: 10, Eviction: EvictOldestBatch(0.3)})
+	fillOffline(c, "info", "info", "info", "info", "info", "info", "info", "info", "info", "info", "info", "info")
+	if c.dropped != 3 || c.buffer.len() != 9 {
+		t.Fatalf("dropped %d, buffered %d", c.dropped, c.buffer.len())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return len(h.ofType("console")) == 9 }, time.Second)
+	if got := consoleMessages(h); got["m2"] || !got["m3"] {
+		t.Fatalf("delivered %v", got)
+	}
+	notices := h.ofType("info")
+	if len(notices) != 1 || notices[0]["message"] != "bridge buffered drop count=3" {
+		t.Fatalf("drop notices %v", notices)
+	}
+}
+
+func TestEvictByPriorityKeepsErrors(t *testing.T) {
+	priority := func(ev map[string]any) int {
+		if ev["level"] == "error" {
+			return 1
+		}
+		return 0
+	}
+	c := NewClient(ClientConfig{URL: "ws://0.0.0.0:1", BufferLimit: 2, Eviction: EvictByPriority(priority)})
+	fillOffline(c, "error", "info", "info", "error", "info")
+	if got := bufferedMessages(c); !reflect.DeepEqual([]any{"m0", "m3"}, got) {
+		t.Fatalf("buffered %v", got)
+	}
+	if c.dropped != 3 {
+		t.Fatalf("dropped %d", c.dropped)
+	}
+}
+
+// keepErrors is a custom policy, so it goes through the generic rebuild.
+type keepErrors struct{}
+
+func (keepErrors) Evict(buffer []map[string]any, incoming map[string]any) ([]map[string]any, int) {
+	var keep []map[string]any
+	for _, ev := range append(buffer, incoming) {
+		if ev["level"] == "error" {
+			keep = append(keep, ev)
+		}
+	}
+	return keep, len(buffer) + 1 - len(keep)
+}
+
+func TestCustomEvictionPolicyRebuildsRing(t *testing.T) {
+	rec := &sinkRecorder{}
+	c := NewClient(ClientConfig{URL: "ws://0.0.0.0:1", BufferLimit: 3, Eviction: keepErrors{}, FallbackSink: rec.sink, FallbackDroppedEvents: true})
+	fillOffline(c, "info", "error", "info", "error")
+	if got := bufferedMessages(c); !reflect.DeepEqual([]any{"m1", "m3"}, got) {
+		t.Fatalf("buffered %v", got)
+	}
+	if got := rec.messages(); !reflect.DeepEqual([]any{"m0", "m2"}, got) {
+		t.Fatalf("fallback received %v", got)
+	}
+}
+
+func TestEvictionPreservesRawBytes(t *testing.T) {
+	c := NewClient(ClientConfig{URL: "ws://0.0.0.0:1", BufferLimit: 1, Eviction: EvictNewest})
+	_ = c.SendRaw([]byte(`{"type":"console", "message":"raw"}`))
+	_ = c.SendConsole("info", "dropped")
+	qs := c.buffer.drain()
+	if len(qs) != 1 || string(qs[0].raw) != `{"type":"console", "message":"raw"}` {
+		t.Fatalf("buffer %+v", qs)
+	}
+}
+
+func TestSendWithPrioritySurvivesEviction(t *testing.T) {
+	priority := func(map[string]any) int { return 0 }
+	c := NewClient(ClientConfig{URL: "ws://0.0.0.0:1", BufferLimit: 2, Eviction: EvictByPriority(priority)})
+	_ = c.SendConsole("info", "m0")
+	_ = c.SendWithPriority(map[string]any{"type": "console", "level": "info", "message": "critical"}, 10)
+	fillOffline(c, "info", "info", "info")
+	got := bufferedMessages(c)
+	if len(got) != 2 || got[0] != "critical" {
+		t.Fatalf("buffered %v", got)
+	}
+}
+
+func BenchmarkEvictionOverflow(b *testing.B) {
+	for _, bc := range []struct {
+		name   string
+		policy EvictionPolicy
+	}{
+		{"default", nil},
+		{"oldest", EvictOldest},
+		{"batch10pct", EvictOldestBatch(0.1)},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			c := NewClient(ClientConfig{URL: "ws://0.0.0.0:1", BufferLimit: 1000, Eviction: bc.policy})
+			ev := map[string]any{"type": "console", "level": "info", "message": "x"}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_ = c.enqueue(copyEvent(ev))
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package ariabridge
+
+import (
+	"expvar"
+	"strconv"
+	"sync"
+)
+
+const expvarPrefixDefault = "ariabridge"
+
+var expvarMu sync.Mutex
+
+// publishExpvar registers c's Stats as an expvar.Func under prefix, adding a
+// numeric suffix when the name is taken so several clients can publish.
+// Expvar has no unregister, so the variable outlives the client.
+func publishExpvar(prefix string, c *Client) string {
+	if prefix == "" {
+		prefix = expvarPrefixDefault
+	}
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+	name := prefix
+	for i := 2; expvar.Get(name) != nil; i++ {
+		name = prefix + "_" + strconv.Itoa(i)
+	}
+	expvar.Publish(name, expvar.Func(func() any { return c.Stats() }))
+	return name
+}
+
+// ExpvarName is the expvar variable holding this client's Stats, or "" when
+// PublishExpvar is off.
+func (c *Client) ExpvarName() string {
+	return c.expvarName
+}
@@ -0,0 +1,35 @@
+package ariabridge
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPublishExpvarStats(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", PublishExpvar: true, ExpvarPrefix: "bridge_test"})
+	other := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", PublishExpvar: true, ExpvarPrefix: "bridge_test"})
+	if !strings.HasPrefix(c.ExpvarName(), "bridge_test") || other.ExpvarName() == c.ExpvarName() {
+		t.Fatalf("names %q %q", c.ExpvarName(), other.ExpvarName())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+	sendGarbage(t, h, "{not json")
+	waitFor(t, func() bool { return c.Stats().MalformedMessages == 1 }, time.Second)
+
+	var st Stats
+	if err := json.Unmarshal([]byte(expvar.Get(c.ExpvarName()).String()), &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.MalformedMessages != 1 {
+		t.Fatalf("published stats %+v", st)
+	}
+}
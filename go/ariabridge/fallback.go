@@ -0,0 +1,83 @@
+package ariabridge
+
+import "time"
+
+// drainToFallback hands every buffered event to FallbackSink so a terminal
+// failure does not silently discard them.
+func (c *Client) drainToFallback() int {
+	if c.cfg.FallbackSink == nil {
+		return 0
+	}
+	return c.drainBuffer()
+}
+
+// drainBuffer empties the buffer, passing each event to FallbackSink when one
+// is set, and returns how many events were removed.
+func (c *Client) drainBuffer() int {
+	c.bufMu.Lock()
+	pending := c.buffer.drain()
+	c.reportDrops(true)
+	c.unlockBuf()
+	if c.cfg.FallbackSink != nil {
+		for _, q := range pending {
+			c.cfg.FallbackSink(q.ev)
+		}
+	}
+	return len(pending)
+}
+
+// CloseWithStats closes the client like Close, then reports how many buffered
+// events were never delivered. Those events are handed to FallbackSink when
+// one is set.
+func (c *Client) CloseWithStats() (undelivered int, err error) {
+	err = c.Close()
+	return c.drainBuffer(), err
+}
+
+// reportDrops records the drops since the last report locally: the count
+// moves into Stats and, when notify is set and there is a FallbackSink, a
+// drop notice is queued for it so the loss is kept even if the client never
+// reconnects. The caller holds bufMu.
+func (c *Client) reportDrops(notify bool) {
+	if c.dropped == 0 {
+		return
+	}
+	if notify && c.cfg.FallbackSink != nil {
+		c.sinkQueue = append(c.sinkQueue, map[string]any{
+			"type":      "info",
+			"level":     "info",
+			"message":   "bridge buffered drop count=" + itoa(c.dropped),
+			"dropped":   c.dropped,
+			"timestamp": time.Now().UnixMilli(),
+		})
+	}
+	c.stats.dropped.Add(uint64(c.dropped))
+	c.dropped = 0
+}
+
+// fallbackDropped queues an event evicted from the buffer for FallbackSink
+// when FallbackDroppedEvents is set. The caller holds bufMu.
+func (c *Client) fallbackDropped(ev map[string]any) {
+	if ev != nil && c.cfg.FallbackDroppedEvents && c.cfg.FallbackSink != nil {
+		c.sinkQueue = append(c.sinkQueue, ev)
+	}
+}
+
+// unlockBuf releases bufMu and then hands the events queued for
+// FallbackSink to it, so a sink that logs or sends through the client
+// cannot deadlock on the buffer. Attachment frames whose metadata was
+// written under the lock are written here too, keeping blobs off bufMu.
+func (c *Client) unlockBuf() {
+	queued, frames := c.sinkQueue, c.frameQueue
+	c.sinkQueue, c.frameQueue = nil, nil
+	c.bufMu.Unlock()
+	for _, ev := range queued {
+		c.cfg.FallbackSink(ev)
+	}
+	for _, f := range frames {
+		if err := c.writeBinary(f); err != nil {
+			c.log("attachment frame: " + err.Error())
+			return
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package ariabridge
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type sinkRecorder struct {
+	mu     sync.Mutex
+	events []map[string]any
+}
+
+func (s *sinkRecorder) sink(ev map[string]any) {
+	s.mu.Lock()
+	s.events = append(s.events, ev)
+	s.mu.Unlock()
+}
+
+func (s *sinkRecorder) messages() []any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []any
+	for _, ev := range s.events {
+		out = append(out, ev["message"])
+	}
+	return out
+}
+
+func TestTerminalFailureDrainsToFallbackSink(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	h.onMessage(func(conn *websocket.Conn, m map[string]any) {
+		if m["type"] == "auth" {
+			_ = conn.Close()
+		}
+	})
+
+	rec := &sinkRecorder{}
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", FallbackSink: rec.sink})
+	_ = c.SendConsole("info", "b0")
+	_ = c.SendConsole("error", "b1")
+
+	done := make(chan error, 1)
+	go func() { done <- c.Start(context.Background()) }()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatalf("start returned nil after rejected auth")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("start did not return")
+	}
+	if got := rec.messages(); !reflect.DeepEqual([]any{"b0", "b1"}, got) {
+		t.Fatalf("fallback received %v", got)
+	}
+}
+
+func TestCloseWithStatsReportsUndelivered(t *testing.T) {
+	rec := &sinkRecorder{}
+	c := NewClient(ClientConfig{URL: "ws://127.0.0.1:1", Secret: "dev-secret", FallbackSink: rec.sink})
+	for _, msg := range []string{"a", "b", "c"} {
+		_ = c.SendConsole("info", msg)
+	}
+
+	n, err := c.CloseWithStats()
+	if err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if n != 3 {
+		t.Fatalf("undelivered = %d", n)
+	}
+	if got := rec.messages(); !reflect.DeepEqual(got, []any{"a", "b", "c"}) {
+		t.Fatalf("fallback got %v", got)
+	}
+	if n, _ := c.CloseWithStats(); n != 0 {
+		t.Fatalf("second close reported %d", n)
+	}
+}
+
+func TestOfflineDropsReachFallbackSink(t *testing.T) {
+	rec := &sinkRecorder{}
+	c := NewClient(ClientConfig{URL: "ws://0.0.0.0:1", BufferLimit: 2, FallbackSink: rec.sink, FallbackDroppedEvents: true})
+	fillOffline(c, "info", "info", "info", "info")
+	if got := rec.messages(); !reflect.DeepEqual(got, []any{"m0", "m1"}) {
+		t.Fatalf("dropped events at sink %v", got)
+	}
+	_ = c.Close()
+	got := rec.messages()
+	if len(got) != 3 || got[2] != "bridge buffered drop count=2" {
+		t.Fatalf("sink %v", got)
+	}
+	if st := c.Stats(); st.Dropped != 2 {
+		t.Fatalf("stats dropped %d", st.Dropped)
+	}
+}
+
+func TestDropNoticeOnlyWithoutDroppedEvents(t *testing.T) {
+	rec := &sinkRecorder{}
+	c := NewClient(ClientConfig{URL: "ws://0.0.0.0:1", BufferLimit: 1, FallbackSink: rec.sink})
+	fillOffline(c, "info", "info", "info")
+	if n, _ := c.CloseWithStats(); n != 1 {
+		t.Fatalf("undelivered %d", n)
+	}
+	if got := rec.messages(); !reflect.DeepEqual(got, []any{"bridge buffered drop count=2", "m2"}) {
+		t.Fatalf("sink %v", got)
+	}
+}
+
+func TestFallbackSinkMayUseClient(t *testing.T) {
+	var c *Client
+	var sunk []any
+	sink := func(ev map[string]any) {
+		sunk = append(sunk, ev["message"])
+		_ = c.Stats()
+		if len(sunk) == 1 {
+			_ = c.SendEvent(map[string]any{"type": "audit", "message": "sink saw a drop"})
+		}
+	}
+	c = NewClient(ClientConfig{URL: "ws://0.0.0.0:1", BufferLimit: 1, FallbackSink: sink, FallbackDroppedEvents: true})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fillOffline(c, "info", "info")
+		_ = c.Close()
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("sink deadlocked on the buffer")
+	}
+	if len(sunk) < 2 || sunk[0] != "m0" {
+		t.Fatalf("sink %v", sunk)
+	}
+}
+
+func TestDropNoticeSentOnlineSkipsSink(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	rec := &sinkRecorder{}
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", BufferLimit: 1, FallbackSink: rec.sink})
+	fillOffline(c, "info", "info")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return len(h.ofType("info")) == 1 }, time.Second)
+	if got := rec.messages(); len(got) != 0 {
+		t.Fatalf("sink got %v for a notice delivered over the wire", got)
+	}
+	if st := c.Stats(); st.Dropped != 1 {
+		t.Fatalf("stats dropped %d", st.Dropped)
+	}
+}
@@ -0,0 +1,70 @@
+package ariabridge
+
+// Server feature names recognised in auth_success. When the server
+// advertises a feature list, client features missing from it are turned off.
+const (
+	FeatureAcks        = "acks"
+	FeatureBatching    = "batching"
+	FeatureCompression = "compression"
+	FeatureFlowControl = "flowControl"
+	FeatureBinaryAcks  = "binaryAcks"
+)
+
+// ServerFeatures returns the features advertised in the last auth_success, or
+// nil if the server did not advertise any.
+func (c *Client) ServerFeatures() map[string]bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.features == nil {
+		return nil
+	}
+	out := make(map[string]bool, len(c.features))
+	for k, v := range c.features {
+		out[k] = v
+	}
+	return out
+}
+
+// observeFeatures reads "features" from auth_success, either as a list of
+// names or as an object of flags.
+func (c *Client) observeFeatures(m map[string]any) {
+	var features map[string]bool
+	switch f := m["features"].(type) {
+	case []any:
+		features = make(map[string]bool, len(f))
+		for _, name := range f {
+			if s, ok := name.(string); ok {
+				features[s] = true
+			}
+		}
+	case map[string]any:
+		features = make(map[string]bool, len(f))
+		for name, on := range f {
+			b, _ := on.(bool)
+			features[name] = b
+		}
+	}
+	c.mu.Lock()
+	c.features = features
+	c.mu.Unlock()
+}
+
+func (c *Client) featureEnabled(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.features == nil || c.features[name]
+}
+
+func (c *Client) flowControl() bool {
+	return c.cfg.FlowControl && c.featureEnabled(FeatureFlowControl)
+}
+
+// requestedFeatures lists the opt-in features the client confirms in hello,
+// those it enabled that the server advertised. The caller holds mu.
+func (c *Client) requestedFeatures() []string {
+	var out []string
+	if c.cfg.BinaryAcks && c.features[FeatureBinaryAcks] {
+		out = append(out, FeatureBinaryAcks)
+	}
+	return out
+}
@@ -0,0 +1,65 @@
+package ariabridge
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestServerFeaturesDisableUnsupportedClientFeatures(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	h.authInfo = map[string]any{"features": []string{FeatureAcks}}
+
+	c := NewClient(ClientConfig{
+		URL:             h.url,
+		Secret:          "dev-secret",
+		FlowControl:     true,
+		AckTimeout:      time.Second,
+		MetricsInterval: time.Hour,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	if f := c.ServerFeatures(); !f[FeatureAcks] || f[FeatureFlowControl] || f[FeatureBatching] {
+		t.Fatalf("server features %v", f)
+	}
+
+	// without flow control the event goes out with no credits granted
+	_ = c.SendConsole("info", "uncredited")
+	waitFor(t, func() bool { return len(h.ofType("console")) == 1 }, time.Second)
+	if _, ok := h.ofType("console")[0]["seq"]; !ok {
+		t.Fatalf("acks are supported, event should carry seq")
+	}
+
+	// without batching each counter is its own metric event
+	c.SendMetric("a", 1, nil)
+	c.SendMetric("b", 2, nil)
+	_ = c.FlushMetrics()
+	waitFor(t, func() bool { return len(h.ofType("metric")) == 2 }, time.Second)
+	if n := len(h.ofType("metrics")); n != 0 {
+		t.Fatalf("sent %d batched metrics frames", n)
+	}
+}
+
+func TestServerFeaturesAbsentKeepsClientFeatures(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", FlowControl: true})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	if f := c.ServerFeatures(); f != nil {
+		t.Fatalf("expected no advertised features, got %v", f)
+	}
+	_ = c.SendConsole("info", "waits for credit")
+	time.Sleep(30 * time.Millisecond)
+	if n := len(h.ofType("console")); n != 0 {
+		t.Fatalf("flow control should still hold events, sent %d", n)
+	}
+}
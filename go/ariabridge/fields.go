@@ -0,0 +1,24 @@
+package ariabridge
+
+// WithFields returns a child client that adds fields to every event it sends.
+// The child shares the parent's connection, buffer and handlers; its fields
+// are merged over the parent's. Keys already present on an event win.
+func (c *Client) WithFields(fields map[string]any) *Client {
+	merged := make(map[string]any, len(c.fields)+len(fields))
+	for k, v := range c.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return &Client{core: c.core, fields: merged}
+}
+
+func (c *Client) stampFields(ev map[string]any) {
+	c.stampScopes(ev)
+	for k, v := range c.fields {
+		if _, ok := ev[k]; !ok {
+			ev[k] = v
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package ariabridge
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWithFieldsMergesOntoChildEvents(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	svc := c.WithFields(map[string]any{"component": "api", "region": "eu"})
+	req := svc.WithFields(map[string]any{"requestId": "r1", "region": "us"})
+	_ = req.SendConsole("info", "child")
+	_ = c.SendConsole("info", "parent")
+
+	waitFor(t, func() bool { return len(h.ofType("console")) == 2 }, time.Second)
+	for _, m := range h.ofType("console") {
+		switch m["message"] {
+		case "child":
+			if m["component"] != "api" || m["region"] != "us" || m["requestId"] != "r1" {
+				t.Fatalf("child fields %v", m)
+			}
+		case "parent":
+			if _, ok := m["component"]; ok {
+				t.Fatalf("parent event carries child fields: %v", m)
+			}
+		}
+	}
+	if svc.State() != StateConnected || h.conns != 1 {
+		t.Fatalf("child should share the connection")
+	}
+}
@@ -0,0 +1,73 @@
+package ariabridge
+
+// takeCredit consumes one send credit. Without FlowControl every send is
+// allowed.
+func (c *Client) takeCredit() bool {
+	if !c.flowControl() {
+		return true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.credits <= 0 {
+		return false
+	}
+	c.credits--
+	return true
+}
+
+func (c *Client) grantCredits(n int) {
+	if !c.flowControl() || n <= 0 {
+		return
+	}
+	c.mu.Lock()
+	c.credits += n
+	c.mu.Unlock()
+	_ = c.flushBuffer()
+}
+
+func (c *Client) resetCredits() {
+	c.mu.Lock()
+	c.credits = 0
+	c.mu.Unlock()
+}
+
+// serverFlush drains the whole buffer on the server's request, regardless of
+// credits, and confirms with flush_ack once the writes are out.
+func (c *Client) serverFlush() {
+	c.bufMu.Lock()
+	before, remaining := 0, 0
+	if c.connAlive() {
+		before = c.buffer.len()
+		if err := c.flushWith(false); err != nil {
+			c.log("server flush: " + err.Error())
+		}
+		remaining = c.buffer.len()
+	}
+	c.unlockBuf()
+	c.notifyFlush(before, remaining)
+	c.checkPressure()
+	c.waitWrites(writeDrainTimeout)
+	_ = c.send(map[string]any{"type": "flush_ack"})
+}
+
+// OnFlushComplete registers a callback run after each flush of a non-empty
+// buffer with how many events were sent and how many are still buffered.
+// With FlowControl a flush can stop early when credits run out, leaving
+// remaining above zero until the next grant.
+func (c *Client) OnFlushComplete(handler func(sent, remaining int)) {
+	c.mu.Lock()
+	c.flushComplete = handler
+	c.mu.Unlock()
+}
+
+func (c *Client) notifyFlush(before, remaining int) {
+	if before == 0 {
+		return
+	}
+	c.mu.Lock()
+	handler := c.flushComplete
+	c.mu.Unlock()
+	if handler != nil {
+		handler(before-remaining, remaining)
+	}
+}
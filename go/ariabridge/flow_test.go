@@ -0,0 +1,142 @@
+package ariabridge
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFlowControlThrottlesToCredits(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", FlowControl: true})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+	for i := 0; i < 5; i++ {
+		_ = c.SendConsole("info", "m"+itoa(i))
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := len(h.ofType("console")); n != 0 {
+		t.Fatalf("sent %d events without credits", n)
+	}
+
+	h.sendJSON(t, map[string]any{"type": "credit", "n": 2})
+	waitFor(t, func() bool { return len(h.ofType("console")) == 2 }, time.Second)
+	time.Sleep(50 * time.Millisecond)
+	if n := len(h.ofType("console")); n != 2 {
+		t.Fatalf("sent %d events with 2 credits", n)
+	}
+
+	first := h.ofType("console")[0]
+	h.sendJSON(t, map[string]any{"type": "ack", "seq": first["seq"]})
+	waitFor(t, func() bool { return len(h.ofType("console")) == 3 }, time.Second)
+
+	h.sendJSON(t, map[string]any{"type": "credit", "n": 10})
+	waitFor(t, func() bool { return len(h.ofType("console")) == 5 }, time.Second)
+	for i, m := range h.ofType("console") {
+		if m["message"] != "m"+itoa(i) {
+			t.Fatalf("event %d out of order: %v", i, m["message"])
+		}
+	}
+}
+
+func TestServerRequestedFlush(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", FlowControl: true})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+	for i := 0; i < 3; i++ {
+		_ = c.SendConsole("info", "m"+itoa(i))
+	}
+	time.Sleep(30 * time.Millisecond)
+	if n := len(h.ofType("console")); n != 0 {
+		t.Fatalf("sent %d events before flush", n)
+	}
+
+	h.sendJSON(t, map[string]any{"type": "flush"})
+	waitFor(t, func() bool { return len(h.ofType("flush_ack")) == 1 }, time.Second)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var order []any
+	for _, m := range h.msgs {
+		if m["type"] == "console" || m["type"] == "flush_ack" {
+			order = append(order, m["type"])
+		}
+	}
+	if len(order) != 4 || order[3] != "flush_ack" {
+		t.Fatalf("expected three events then flush_ack, got %v", order)
+	}
+}
+
+type flushReports struct {
+	mu   sync.Mutex
+	seen [][2]int
+}
+
+func (r *flushReports) record(sent, remaining int) {
+	r.mu.Lock()
+	r.seen = append(r.seen, [2]int{sent, remaining})
+	r.mu.Unlock()
+}
+
+func (r *flushReports) snapshot() [][2]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([][2]int(nil), r.seen...)
+}
+
+func TestOnFlushCompleteAfterReconnect(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", BackoffInitial: 200 * time.Millisecond})
+	reports := &flushReports{}
+	c.OnFlushComplete(reports.record)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+	h.mu.Lock()
+	_ = h.conn.Close()
+	h.mu.Unlock()
+	waitFor(t, func() bool { return c.State() == StateReconnecting }, time.Second)
+	for i := 0; i < 3; i++ {
+		_ = c.SendConsole("info", "m"+itoa(i))
+	}
+
+	waitFor(t, func() bool { return len(reports.snapshot()) == 1 }, 2*time.Second)
+	if got := reports.snapshot()[0]; got != [2]int{3, 0} {
+		t.Fatalf("flush report %v", got)
+	}
+}
+
+func TestOnFlushCompleteReportsPartialFlush(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", FlowControl: true})
+	reports := &flushReports{}
+	c.OnFlushComplete(reports.record)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+	for i := 0; i < 5; i++ {
+		_ = c.SendConsole("info", "m"+itoa(i))
+	}
+	h.sendJSON(t, map[string]any{"type": "credit", "n": 2})
+	waitFor(t, func() bool { return len(reports.snapshot()) == 1 }, time.Second)
+	if got := reports.snapshot()[0]; got != [2]int{2, 3} {
+		t.Fatalf("flush report %v", got)
+	}
+}
@@ -0,0 +1,62 @@
+package ariabridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Conn is the connection an Authenticator drives. Writes use the client's
+// marshalling and framing; reads give up with ErrAuthTimeout once
+// HeartbeatTimeout or ConnectTimeout runs out.
+type Conn interface {
+	WriteJSON(v any) error
+	ReadJSON(v any) error
+}
+
+// Authenticator replaces the built-in auth and hello exchange for servers
+// that expect a different sequence. It must leave the connection ready for
+// events, sending hello itself if the server wants one. ErrAuthTimeout is
+// retried like a built-in auth timeout; other errors end Start.
+type Authenticator interface {
+	Authenticate(ctx context.Context, conn Conn, cfg ClientConfig) error
+}
+
+type handshakeConn struct {
+	c        *Client
+	ctx      context.Context
+	conn     *websocket.Conn
+	deadline time.Time
+}
+
+func (h *handshakeConn) WriteJSON(v any) error {
+	return h.c.send(v)
+}
+
+func (h *handshakeConn) ReadJSON(v any) error {
+	h.conn.SetReadDeadline(h.deadline)
+	_, data, err := h.conn.ReadMessage()
+	if err != nil {
+		var ne net.Error
+		if errors.As(err, &ne) && ne.Timeout() {
+			return h.c.authDeadlineErr(h.ctx)
+		}
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (c *Client) authenticate(ctx context.Context, conn *websocket.Conn) error {
+	deadline := time.Now().Add(c.cfg.HeartbeatTimeout)
+	if dl, ok := ctx.Deadline(); ok && dl.Before(deadline) {
+		deadline = dl
+	}
+	c.mu.Lock()
+	cfg := c.cfg
+	c.mu.Unlock()
+	return c.cfg.Authenticator.Authenticate(ctx, &handshakeConn{c: c, ctx: ctx, conn: conn, deadline: deadline}, cfg)
+}
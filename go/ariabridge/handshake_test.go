@@ -0,0 +1,78 @@
+package ariabridge
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// challengeAuth answers a server challenge with an AuthSignature over its
+// nonce instead of sending auth.
+type challengeAuth struct{}
+
+func (challengeAuth) Authenticate(ctx context.Context, conn Conn, cfg ClientConfig) error {
+	if err := conn.WriteJSON(map[string]any{"type": "challenge_request"}); err != nil {
+		return err
+	}
+	for {
+		var m map[string]any
+		if err := conn.ReadJSON(&m); err != nil {
+			return err
+		}
+		switch m["type"] {
+		case "challenge":
+			nonce, _ := m["nonce"].(string)
+			if err := conn.WriteJSON(map[string]any{"type": "challenge_response", "signature": AuthSignature(cfg.Secret, nonce, 0)}); err != nil {
+				return err
+			}
+		case "auth_success":
+			return nil
+		case "auth_failed":
+			return errors.New("challenge rejected")
+		}
+	}
+}
+
+func TestCustomAuthenticatorChallengeResponse(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	h.onMessage(func(conn *websocket.Conn, m map[string]any) {
+		switch m["type"] {
+		case "challenge_request":
+			_ = conn.WriteJSON(map[string]any{"type": "challenge", "nonce": "n-1"})
+		case "challenge_response":
+			reply := "auth_failed"
+			if m["signature"] == AuthSignature("dev-secret", "n-1", 0) {
+				reply = "auth_success"
+			}
+			_ = conn.WriteJSON(map[string]any{"type": reply})
+		}
+	})
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", Authenticator: challengeAuth{}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	_ = c.SendConsole("info", "after challenge")
+	waitFor(t, func() bool { return len(h.ofType("console")) == 1 }, time.Second)
+	if n := len(h.ofType("auth")) + len(h.ofType("hello")); n != 0 {
+		t.Fatalf("built-in handshake sent %d messages", n)
+	}
+}
+
+func TestCustomAuthenticatorTimesOut(t *testing.T) {
+	srv, _ := silentServer(t)
+	defer srv.Close()
+
+	c := NewClient(ClientConfig{URL: "ws" + srv.URL[4:], Secret: "dev-secret", HeartbeatTimeout: 30 * time.Millisecond, Authenticator: challengeAuth{}})
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := c.Start(ctx); !errors.Is(err, ErrAuthTimeout) {
+		t.Fatalf("expected ErrAuthTimeout, got %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+package ariabridge
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	adaptiveRTTFactor = 4
+	rttSmoothing      = 0.2
+	pongBufferDefault = 8
+)
+
+// pong is a pong frame stamped by the reader with its arrival time, so RTT
+// does not include time spent waiting for the heartbeat loop, and with the
+// ping generation current when it arrived. Matching pongs to pings by
+// generation rather than by time keeps a stepped clock from discarding them.
+type pong struct {
+	msg map[string]any
+	at  time.Time
+	gen uint64
+}
+
+// deliverPong hands p to the heartbeat loop without blocking the reader. Only
+// one ping is outstanding at a time, so when the buffer is full the oldest
+// pong is dropped: the newest is the one that can answer the current ping.
+// Pongs from an earlier generation answer an earlier ping and only extend the
+// read deadline.
+func (c *Client) deliverPong(p pong) {
+	for {
+		select {
+		case c.pongCh <- p:
+			return
+		default:
+		}
+		select {
+		case <-c.pongCh:
+		default:
+		}
+	}
+}
+
+// observeRTT folds a ping round trip into the moving average used by the
+// adaptive heartbeat timeout.
+func (c *Client) observeRTT(rtt time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.avgRTT == 0 {
+		c.avgRTT = rtt
+		return
+	}
+	c.avgRTT = time.Duration(rttSmoothing*float64(rtt) + (1-rttSmoothing)*float64(c.avgRTT))
+}
+
+// pingMessage builds the heartbeat ping. With PingPayloadFn set, its fields
+// are added to the ping and returned so the echoing pong can be matched.
+func (c *Client) pingMessage() (any, map[string]any) {
+	if c.cfg.PingPayloadFn == nil {
+		return PingMessage{Type: "ping"}, nil
+	}
+	payload := c.cfg.PingPayloadFn()
+	ping := make(map[string]any, len(payload)+1)
+	for k, v := range payload {
+		ping[k] = v
+	}
+	ping["type"] = "ping"
+	return ping, payload
+}
+
+// pongMatches reports whether pong echoes every field of the ping payload.
+// Values are compared by their JSON encoding since the pong was decoded.
+func pongMatches(payload, pong map[string]any) bool {
+	for k, v := range payload {
+		want, err1 := json.Marshal(v)
+		got, err2 := json.Marshal(pong[k])
+		if err1 != nil || err2 != nil || string(want) != string(got) {
+			return false
+		}
+	}
+	return true
+}
+
+// heartbeatTimeout is the read deadline applied after each ping or pong.
+func (c *Client) heartbeatTimeout() time.Duration {
+	if !c.cfg.AdaptiveHeartbeat {
+		return c.cfg.HeartbeatTimeout
+	}
+	c.mu.Lock()
+	avg := c.avgRTT
+	c.mu.Unlock()
+	if avg == 0 {
+		return c.cfg.HeartbeatTimeout
+	}
+	timeout := adaptiveRTTFactor * avg
+	if timeout < c.cfg.HeartbeatTimeoutMin {
+		timeout = c.cfg.HeartbeatTimeoutMin
+	}
+	if timeout > c.cfg.HeartbeatTimeoutMax {
+		timeout = c.cfg.HeartbeatTimeoutMax
+	}
+	return timeout
+}
+
+// SuspendHeartbeat stops pings and lifts the read deadline so an idle,
+// backgrounded app keeps its connection without spending battery on it.
+func (c *Client) SuspendHeartbeat() {
+	c.bufMu.Lock()
+	conn := c.conn
+	c.bufMu.Unlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hbSuspended = true
+	if conn != nil {
+		conn.SetReadDeadline(time.Time{})
+	}
+}
+
+func (c *Client) ResumeHeartbeat() {
+	c.bufMu.Lock()
+	conn := c.conn
+	c.bufMu.Unlock()
+	c.mu.Lock()
+	c.hbSuspended = false
+	c.mu.Unlock()
+	if conn != nil {
+		c.extendDeadline(conn)
+	}
+}
+
+func (c *Client) heartbeatSuspended() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hbSuspended
+}
+
+// extendDeadline pushes the read deadline out by the heartbeat timeout, or
+// clears it while heartbeats are suspended.
+func (c *Client) extendDeadline(conn *websocket.Conn) {
+	timeout := c.heartbeatTimeout()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.hbSuspended {
+		conn.SetReadDeadline(time.Time{})
+		return
+	}
+	// time.Now, not c.clockNow: the deadline must carry a monotonic reading
+	conn.SetReadDeadline(time.Now().Add(timeout))
+}
@@ -0,0 +1,200 @@
+package ariabridge
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestAdaptiveHeartbeatTimeoutTracksRTT(t *testing.T) {
+	c := NewClient(ClientConfig{
+		URL:                 "ws://0.0.0.0:1",
+		AdaptiveHeartbeat:   true,
+		HeartbeatTimeout:    100 * time.Millisecond,
+		HeartbeatTimeoutMax: time.Second,
+	})
+	if got := c.heartbeatTimeout(); got != 100*time.Millisecond {
+		t.Fatalf("timeout before samples %v", got)
+	}
+
+	for i := 0; i < 20; i++ {
+		c.observeRTT(10 * time.Millisecond)
+	}
+	if got := c.heartbeatTimeout(); got != 100*time.Millisecond {
+		t.Fatalf("low rtt timeout %v, want clamp to min", got)
+	}
+
+	for i := 0; i < 50; i++ {
+		c.observeRTT(200 * time.Millisecond)
+	}
+	if got := c.heartbeatTimeout(); got < 750*time.Millisecond || got > 800*time.Millisecond {
+		t.Fatalf("high rtt timeout %v", got)
+	}
+
+	for i := 0; i < 50; i++ {
+		c.observeRTT(2 * time.Second)
+	}
+	if got := c.heartbeatTimeout(); got != time.Second {
+		t.Fatalf("very high rtt timeout %v, want clamp to max", got)
+	}
+}
+
+func TestHeartbeatTimeoutFixedWhenNotAdaptive(t *testing.T) {
+	c := NewClient(ClientConfig{URL: "ws://0.0.0.0:1", HeartbeatTimeout: 100 * time.Millisecond})
+	c.observeRTT(time.Second)
+	if got := c.heartbeatTimeout(); got != 100*time.Millisecond {
+		t.Fatalf("timeout %v", got)
+	}
+}
+
+func TestSuspendHeartbeatStopsPings(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", HeartbeatInterval: 15 * time.Millisecond, HeartbeatTimeout: 40 * time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+
+	waitFor(t, func() bool { return len(h.ofType("ping")) >= 2 }, time.Second)
+	c.SuspendHeartbeat()
+	time.Sleep(30 * time.Millisecond)
+	before := len(h.ofType("ping"))
+	time.Sleep(150 * time.Millisecond)
+	if after := len(h.ofType("ping")); after != before {
+		t.Fatalf("pings sent while suspended: %d -> %d", before, after)
+	}
+	if c.State() != StateConnected || len(h.ofType("hello")) != 1 {
+		t.Fatalf("connection dropped while suspended")
+	}
+
+	c.ResumeHeartbeat()
+	waitFor(t, func() bool { return len(h.ofType("ping")) >= before+2 }, time.Second)
+}
+
+func TestPingPayloadEchoMatchesPong(t *testing.T) {
+	for _, echo := range []bool{true, false} {
+		h := newHarness(t, true)
+		if echo {
+			h.onMessage(func(conn *websocket.Conn, m map[string]any) {
+				if m["type"] == "ping" {
+					_ = conn.WriteJSON(map[string]any{"type": "pong", "nonce": m["nonce"]})
+				}
+			})
+		}
+		var n atomic.Int32
+		c := NewClient(ClientConfig{
+			URL:               h.url,
+			Secret:            "dev-secret",
+			HeartbeatInterval: 20 * time.Millisecond,
+			PingPayloadFn: func() map[string]any {
+				return map[string]any{"nonce": n.Add(1)}
+			},
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		go c.Start(ctx)
+
+		waitFor(t, func() bool { return len(h.ofType("ping")) >= 3 }, time.Second)
+		time.Sleep(30 * time.Millisecond)
+		if ping := h.ofType("ping")[0]; ping["nonce"] != float64(1) {
+			t.Fatalf("ping payload %v", ping)
+		}
+		c.mu.Lock()
+		rtt := c.avgRTT
+		c.mu.Unlock()
+		if echo && rtt == 0 {
+			t.Fatalf("echoed pong was not used for RTT")
+		}
+		if !echo && rtt != 0 {
+			t.Fatalf("non-matching pong was used for RTT: %v", rtt)
+		}
+		cancel()
+		h.close()
+	}
+}
+
+func TestMissedPongHysteresis(t *testing.T) {
+	for _, tc := range []struct {
+		skip      int
+		reconnect bool
+	}{{1, false}, {100, true}} {
+		h := newHarness(t, true)
+		h.skipPongs = tc.skip
+		c := NewClient(ClientConfig{
+			URL:                        h.url,
+			Secret:                     "dev-secret",
+			HeartbeatInterval:          20 * time.Millisecond,
+			MissedPongsBeforeReconnect: 2,
+			BackoffInitial:             5 * time.Millisecond,
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		go c.Start(ctx)
+
+		waitFor(t, func() bool { return len(h.ofType("ping")) >= 6 || len(h.ofType("auth")) > 1 }, time.Second)
+		if n := len(h.ofType("auth")); (n > 1) != tc.reconnect {
+			t.Fatalf("skip %d: %d connections", tc.skip, n)
+		}
+		cancel()
+		h.close()
+	}
+}
+
+func TestDeliverPongKeepsNewestUnderBurst(t *testing.T) {
+	c := NewClient(ClientConfig{URL: "ws://127.0.0.1:1", Secret: "dev-secret", PongBufferSize: 2})
+	start := time.Now()
+	for i := 0; i < 50; i++ {
+		c.deliverPong(pong{msg: map[string]any{"n": float64(i)}, at: time.Now()})
+	}
+	var last pong
+	for len(c.pongCh) > 0 {
+		last = <-c.pongCh
+	}
+	if last.msg["n"] != float64(49) {
+		t.Fatalf("newest pong lost, last delivered %v", last.msg)
+	}
+	if last.at.Before(start) {
+		t.Fatalf("pong not stamped on arrival")
+	}
+}
+
+func TestBackwardClockStepDoesNotMissPongs(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	c := NewClient(ClientConfig{
+		URL:                        h.url,
+		Secret:                     "dev-secret",
+		HeartbeatInterval:          20 * time.Millisecond,
+		MissedPongsBeforeReconnect: 2,
+		AdaptiveHeartbeat:          true,
+		BackoffInitial:             5 * time.Millisecond,
+	})
+	// the heartbeat clock simulates wall-clock steps such as NTP corrections
+	// or VM resume
+	var mu sync.Mutex
+	steps := 0
+	c.clockNow = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		steps++
+		// wall reading only, an hour further back on every call
+		return time.Now().Round(0).Add(-time.Duration(steps) * time.Hour)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+
+	waitFor(t, func() bool { return len(h.ofType("ping")) >= 6 }, 2*time.Second)
+	if n := len(h.ofType("auth")); n != 1 {
+		t.Fatalf("clock step caused %d connections", n)
+	}
+	c.mu.Lock()
+	rtt := c.avgRTT
+	c.mu.Unlock()
+	if rtt < 0 {
+		t.Fatalf("negative RTT %v", rtt)
+	}
+}
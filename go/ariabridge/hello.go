@@ -0,0 +1,66 @@
+package ariabridge
+
+import "time"
+
+const rehelloDebounceDefault = 250 * time.Millisecond
+
+// SetCapabilities replaces the advertised capabilities. A live connection is
+// told about the change with a debounced rehello.
+func (c *Client) SetCapabilities(caps []string) {
+	c.mu.Lock()
+	c.cfg.Capabilities = append([]string(nil), caps...)
+	c.mu.Unlock()
+	c.scheduleRehello()
+}
+
+// SetMetadata replaces the metadata sent with hello. A live connection is
+// told about the change with a debounced rehello.
+func (c *Client) SetMetadata(md map[string]string) {
+	copied := make(map[string]string, len(md))
+	for k, v := range md {
+		copied[k] = v
+	}
+	c.mu.Lock()
+	c.cfg.Metadata = copied
+	c.mu.Unlock()
+	c.scheduleRehello()
+}
+
+func (c *Client) helloMessage(typ string) HelloMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return HelloMessage{
+		Type:         typ,
+		Capabilities: c.cfg.Capabilities,
+		Platform:     "go",
+		ProjectID:    c.cfg.ProjectID,
+		Protocol:     ProtocolVersion,
+		Metadata:     c.cfg.Metadata,
+		Features:     c.requestedFeatures(),
+	}
+}
+
+func (c *Client) scheduleRehello() {
+	if c.cfg.SkipHello || c.State() != StateConnected {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.rehelloTimer != nil {
+		c.rehelloTimer.Reset(c.cfg.RehelloDebounce)
+		return
+	}
+	c.rehelloTimer = time.AfterFunc(c.cfg.RehelloDebounce, c.rehello)
+}
+
+func (c *Client) rehello() {
+	c.mu.Lock()
+	c.rehelloTimer = nil
+	c.mu.Unlock()
+	if c.State() != StateConnected {
+		return
+	}
+	if err := c.send(c.helloMessage("rehello")); err != nil {
+		c.log("rehello: " + err.Error())
+	}
+}
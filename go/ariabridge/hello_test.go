@@ -0,0 +1,68 @@
+package ariabridge
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSetCapabilitiesDebouncesRehello(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", RehelloDebounce: 50 * time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	c.SetCapabilities([]string{"console", "network"})
+	c.SetCapabilities([]string{"console", "network", "metrics"})
+
+	waitFor(t, func() bool { return len(h.ofType("rehello")) == 1 }, time.Second)
+	time.Sleep(150 * time.Millisecond)
+	got := h.ofType("rehello")
+	if len(got) != 1 {
+		t.Fatalf("expected one rehello, got %d", len(got))
+	}
+	caps := got[0]["capabilities"].([]any)
+	if len(caps) != 3 || caps[2] != "metrics" {
+		t.Fatalf("rehello capabilities %v", caps)
+	}
+}
+
+func TestSetMetadataBeforeConnectGoesInHello(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", RehelloDebounce: 10 * time.Millisecond})
+	c.SetMetadata(map[string]string{"env": "staging"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+
+	waitFor(t, func() bool { return len(h.ofType("hello")) == 1 }, time.Second)
+	if md := h.ofType("hello")[0]["metadata"].(map[string]any); md["env"] != "staging" {
+		t.Fatalf("hello metadata %v", md)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := len(h.ofType("rehello")); n != 0 {
+		t.Fatalf("unexpected rehello before connect: %d", n)
+	}
+}
+
+func TestSkipHelloStillSendsEvents(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", SkipHello: true, RehelloDebounce: 10 * time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	c.SetMetadata(map[string]string{"env": "staging"})
+	_ = c.SendConsole("info", "hi")
+	waitFor(t, func() bool { return len(h.ofType("console")) == 1 }, time.Second)
+	time.Sleep(50 * time.Millisecond)
+	if n := len(h.ofType("hello")) + len(h.ofType("rehello")); n != 0 {
+		t.Fatalf("%d hello messages sent with SkipHello", n)
+	}
+}
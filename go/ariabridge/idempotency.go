@@ -0,0 +1,50 @@
+package ariabridge
+
+import (
+	"errors"
+	"time"
+)
+
+const idempotencyWindowDefault = time.Minute
+
+// SendIdempotent enqueues ev stamped with idempotencyKey, unless an event
+// with the same key was sent within IdempotencyWindow, in which case it is
+// skipped and nil returned. A send that fails does not claim the key.
+func (c *Client) SendIdempotent(key string, ev map[string]any) error {
+	if t, _ := ev["type"].(string); t == "" {
+		return errors.New("event missing type")
+	}
+	if !c.claimIdempotencyKey(key) {
+		return nil
+	}
+	ev = copyEvent(ev)
+	ev["idempotencyKey"] = key
+	if err := c.enqueue(ev); err != nil {
+		c.mu.Lock()
+		delete(c.idempotent, key)
+		c.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// claimIdempotencyKey records key unless it is still within the window,
+// pruning expired keys as it goes.
+func (c *Client) claimIdempotencyKey(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for k, at := range c.idempotent {
+		if now.Sub(at) > c.cfg.IdempotencyWindow {
+			delete(c.idempotent, k)
+		}
+	}
+	if _, ok := c.idempotent[key]; ok {
+		return false
+	}
+	if c.idempotent == nil {
+		c.idempotent = map[string]time.Time{}
+	}
+	c.idempotent[key] = now
+	return true
+}
@@ -0,0 +1,46 @@
+package ariabridge
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSendIdempotentSkipsDuplicateKey(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", Capabilities: []string{"console", "order"}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	ev := map[string]any{"type": "order", "total": 12}
+	for i := 0; i < 2; i++ {
+		if err := c.SendIdempotent("order-1", ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = c.SendIdempotent("order-2", ev)
+	waitFor(t, func() bool { return len(h.ofType("order")) >= 2 }, time.Second)
+	time.Sleep(20 * time.Millisecond)
+
+	got := h.ofType("order")
+	if len(got) != 2 || got[0]["idempotencyKey"] != "order-1" || got[1]["idempotencyKey"] != "order-2" {
+		t.Fatalf("events %v", got)
+	}
+	if _, ok := ev["idempotencyKey"]; ok {
+		t.Fatalf("caller's event was modified")
+	}
+}
+
+func TestSendIdempotentKeyExpires(t *testing.T) {
+	c := NewClient(ClientConfig{URL: "ws://0.0.0.0:1", Secret: "dev-secret", IdempotencyWindow: 20 * time.Millisecond})
+	_ = c.SendIdempotent("k", map[string]any{"type": "console", "message": "first"})
+	time.Sleep(40 * time.Millisecond)
+	_ = c.SendIdempotent("k", map[string]any{"type": "console", "message": "retry"})
+	if got := bufferedMessages(c); len(got) != 2 {
+		t.Fatalf("buffered %v", got)
+	}
+}
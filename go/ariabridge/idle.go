@@ -0,0 +1,43 @@
+package ariabridge
+
+import (
+	"context"
+	"time"
+)
+
+// idleFor reports how long the connection has gone without an event written
+// or a control request received. Heartbeats do not count.
+func (c *Client) idleFor() time.Duration {
+	last := c.lastSend.Load()
+	if active := c.lastActive.Load(); active > last {
+		last = active
+	}
+	return monoNow() - time.Duration(last)
+}
+
+// wakeIdle lets a client suspended after IdleTimeout reconnect. It is called
+// whenever an event is buffered or evicts another, and when a send that needs
+// the connection finds none.
+func (c *Client) wakeIdle() {
+	select {
+	case c.idleWake <- struct{}{}:
+	default:
+	}
+}
+
+// waitIdle suspends the connect loop after an idle close until wakeIdle is
+// called or ctx is done.
+func (c *Client) waitIdle(ctx context.Context) error {
+	c.bufMu.Lock()
+	buffered := c.buffer.len()
+	c.bufMu.Unlock()
+	if buffered > 0 {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.idleWake:
+		return nil
+	}
+}
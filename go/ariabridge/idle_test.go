@@ -0,0 +1,69 @@
+package ariabridge
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestIdleTimeoutClosesAndSendReconnects(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", IdleTimeout: 150 * time.Millisecond, HeartbeatInterval: 20 * time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+	_ = c.SendConsole("info", "before")
+
+	// pings keep flowing, but only events count as activity
+	waitFor(t, func() bool { return c.State() == StateSuspended }, time.Second)
+	if len(h.ofType("ping")) == 0 {
+		t.Fatalf("no heartbeats while connected")
+	}
+	time.Sleep(50 * time.Millisecond)
+	h.mu.Lock()
+	conns := h.conns
+	h.mu.Unlock()
+	if conns != 1 {
+		t.Fatalf("redialed %d times while idle", conns-1)
+	}
+
+	_ = c.SendConsole("info", "wake")
+	waitFor(t, func() bool { return consoleMessages(h)["wake"] }, time.Second)
+	if n := len(h.ofType("hello")); n != 2 {
+		t.Fatalf("%d hellos", n)
+	}
+	if c.State() != StateConnected {
+		t.Fatalf("state %s", c.State())
+	}
+}
+
+func TestSuspendedClientWakesOnQueryAndAttachment(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", Capabilities: []string{"console", "attachments"}, IdleTimeout: 100 * time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+
+	waitFor(t, func() bool { return c.State() == StateSuspended }, time.Second)
+	qctx, qcancel := context.WithTimeout(ctx, time.Second)
+	defer qcancel()
+	if _, err := c.RequestServerLogs(qctx, time.Now()); !errors.Is(err, errNotConnected) {
+		t.Fatalf("query while suspended returned %v", err)
+	}
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	waitFor(t, func() bool { return c.State() == StateSuspended }, time.Second)
+	if err := c.SendAttachment("console", nil, []byte("x")); !errors.Is(err, errNotConnected) {
+		t.Fatalf("attachment while suspended returned %v", err)
+	}
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+	if n := len(h.ofType("hello")); n != 3 {
+		t.Fatalf("%d hellos", n)
+	}
+}
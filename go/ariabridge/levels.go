@@ -0,0 +1,80 @@
+package ariabridge
+
+import (
+	"strings"
+	"time"
+)
+
+var levelSeverity = map[string]int{
+	"trace":   0,
+	"debug":   1,
+	"info":    2,
+	"log":     2,
+	"warn":    3,
+	"warning": 3,
+	"error":   4,
+	"fatal":   5,
+}
+
+func severity(level string) (int, bool) {
+	s, ok := levelSeverity[strings.ToLower(level)]
+	return s, ok
+}
+
+// Level returns the minimum console level pushed by the server, or "" when
+// no filter is active.
+func (c *Client) Level() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.minLevel
+}
+
+func (c *Client) setLevel(level string) {
+	if _, ok := severity(level); !ok && level != "" {
+		return
+	}
+	c.mu.Lock()
+	c.minLevel = level
+	c.mu.Unlock()
+}
+
+// BoostLevel lowers the minimum console level to level for d, then the
+// server's filter applies again. A level above the current filter is ignored.
+func (c *Client) BoostLevel(level string, d time.Duration) {
+	if _, ok := severity(level); !ok {
+		return
+	}
+	c.mu.Lock()
+	c.boostLevel = level
+	c.boostUntil = time.Now().Add(d)
+	c.mu.Unlock()
+}
+
+func (c *Client) effectiveLevel() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.minLevel == "" || c.boostLevel == "" || !time.Now().Before(c.boostUntil) {
+		return c.minLevel
+	}
+	boost, _ := severity(c.boostLevel)
+	if min, ok := severity(c.minLevel); ok && boost < min {
+		return c.boostLevel
+	}
+	return c.minLevel
+}
+
+func (c *Client) levelAllowed(ev map[string]any) bool {
+	if ev["type"] != "console" {
+		return true
+	}
+	level, _ := ev["level"].(string)
+	if !c.subscribedLevel(level) {
+		return false
+	}
+	min, ok := severity(c.effectiveLevel())
+	if !ok {
+		return true
+	}
+	s, ok := severity(level)
+	return !ok || s >= min
+}
@@ -0,0 +1,69 @@
+package ariabridge
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestServerSetLevelFiltersConsole(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+
+	waitFor(t, func() bool { return len(h.ofType("hello")) == 1 }, time.Second)
+	h.sendJSON(t, map[string]any{"type": "set_level", "level": "warn"})
+	waitFor(t, func() bool { return c.Level() == "warn" }, time.Second)
+
+	_ = c.SendConsole("info", "suppressed")
+	_ = c.SendConsole("error", "kept")
+	waitFor(t, func() bool { return len(h.ofType("console")) >= 1 }, time.Second)
+	for _, m := range h.ofType("console") {
+		if m["message"] == "suppressed" {
+			t.Fatalf("info console passed warn filter")
+		}
+	}
+}
+
+func TestSetLevelIgnoresUnknownLevel(t *testing.T) {
+	c := NewClient(ClientConfig{URL: "ws://0.0.0.0:1", Secret: "dev-secret"})
+	c.setLevel("warn")
+	c.setLevel("loud")
+	if c.Level() != "warn" {
+		t.Fatalf("level %q", c.Level())
+	}
+}
+
+func TestBoostLevelExpires(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret"})
+	c.setLevel("warn")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	c.BoostLevel("debug", 100*time.Millisecond)
+	_ = c.SendConsole("debug", "boosted")
+	time.Sleep(150 * time.Millisecond)
+	_ = c.SendConsole("debug", "expired")
+	_ = c.SendConsole("error", "marker")
+	waitFor(t, func() bool { return len(h.ofType("console")) >= 2 }, time.Second)
+
+	var got []any
+	for _, m := range h.ofType("console") {
+		got = append(got, m["message"])
+	}
+	if len(got) != 2 || got[0] != "boosted" || got[1] != "marker" {
+		t.Fatalf("console messages %v", got)
+	}
+	if c.Level() != "warn" {
+		t.Fatalf("level %q", c.Level())
+	}
+}
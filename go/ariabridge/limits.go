@@ -0,0 +1,84 @@
+package ariabridge
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+var (
+	ErrEventTooDeep  = errors.New("event nested too deeply")
+	ErrEventTooLarge = errors.New("event too large")
+)
+
+// checkEventLimits rejects events beyond MaxEventDepth or MaxEventBytes,
+// passing them to FallbackSink. Depth is checked first so a pathological
+// event is never marshaled. Raw events are measured as given.
+func (c *Client) checkEventLimits(q queued) error {
+	var err error
+	if max := c.cfg.MaxEventDepth; max > 0 {
+		if d := depth(reflect.ValueOf(q.ev), max+1); d > max {
+			err = fmt.Errorf("%w: more than %d levels", ErrEventTooDeep, max)
+		}
+	}
+	if err == nil && c.cfg.MaxEventBytes > 0 {
+		size := len(q.raw)
+		if q.raw == nil {
+			data, merr := c.cfg.Marshal(q.ev)
+			if merr != nil {
+				return merr
+			}
+			size = len(data)
+		}
+		if size > c.cfg.MaxEventBytes {
+			err = fmt.Errorf("%w: %d bytes, limit %d", ErrEventTooLarge, size, c.cfg.MaxEventBytes)
+		}
+	}
+	if err != nil && c.cfg.FallbackSink != nil {
+		c.cfg.FallbackSink(q.ev)
+	}
+	return err
+}
+
+// depth returns how deeply v nests maps, slices and structs of any type, a
+// flat event being 1. It stops descending once limit is reached.
+func depth(v reflect.Value, limit int) int {
+	if limit <= 0 {
+		return 0
+	}
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return 0
+		}
+		v = v.Elem()
+	}
+	deepest := 0
+	deeper := func(e reflect.Value) {
+		if d := depth(e, limit-1); d > deepest {
+			deepest = d
+		}
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		for it := v.MapRange(); it.Next(); {
+			deeper(it.Value())
+		}
+	case reflect.Slice, reflect.Array:
+		// byte slices encode as strings
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return 0
+		}
+		for i := 0; i < v.Len(); i++ {
+			deeper(v.Index(i))
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				deeper(v.Field(i))
+			}
+		}
+	default:
+		return 0
+	}
+	return deepest + 1
+}
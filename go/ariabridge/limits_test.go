@@ -0,0 +1,79 @@
+package ariabridge
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func nested(levels int) map[string]any {
+	ev := map[string]any{"leaf": true}
+	for i := 1; i < levels; i++ {
+		ev = map[string]any{"child": ev}
+	}
+	return ev
+}
+
+func TestMaxEventDepthRejectsDeepEvents(t *testing.T) {
+	rec := &sinkRecorder{}
+	c := NewClient(ClientConfig{URL: "ws://0.0.0.0:1", MaxEventDepth: 4, FallbackSink: rec.sink})
+
+	ok := map[string]any{"type": "info", "data": nested(3)}
+	if err := c.SendEvent(ok); err != nil {
+		t.Fatalf("event at the limit rejected: %v", err)
+	}
+	deep := map[string]any{"type": "info", "message": "deep", "data": nested(4)}
+	if err := c.SendEvent(deep); !errors.Is(err, ErrEventTooDeep) {
+		t.Fatalf("deep event returned %v", err)
+	}
+	if got := rec.messages(); len(got) != 1 || got[0] != "deep" {
+		t.Fatalf("fallback received %v", got)
+	}
+	if n := c.buffer.len(); n != 1 {
+		t.Fatalf("buffered %d events", n)
+	}
+}
+
+func TestMaxEventBytesRejectsLargeEvents(t *testing.T) {
+	rec := &sinkRecorder{}
+	c := NewClient(ClientConfig{URL: "ws://0.0.0.0:1", MaxEventBytes: 256, FallbackSink: rec.sink})
+
+	if err := c.SendConsole("info", "small"); err != nil {
+		t.Fatalf("small event rejected: %v", err)
+	}
+	if err := c.SendConsole("info", strings.Repeat("x", 300)); !errors.Is(err, ErrEventTooLarge) {
+		t.Fatalf("large event returned %v", err)
+	}
+	if n := len(rec.messages()); n != 1 {
+		t.Fatalf("fallback received %d events", n)
+	}
+	if n := c.buffer.len(); n != 1 {
+		t.Fatalf("buffered %d events", n)
+	}
+}
+
+func TestMaxEventDepthSeesTypedContainers(t *testing.T) {
+	c := NewClient(ClientConfig{URL: "ws://0.0.0.0:1", MaxEventDepth: 3})
+	rows := map[string]any{"type": "info", "rows": []map[string]any{{"tags": map[string]string{"a": "b"}}}}
+	if err := c.SendEvent(rows); !errors.Is(err, ErrEventTooDeep) {
+		t.Fatalf("typed nesting returned %v", err)
+	}
+	flat := map[string]any{"type": "info", "tags": map[string]string{"a": "b"}, "blob": []byte("xyz")}
+	if err := c.SendEvent(flat); err != nil {
+		t.Fatalf("flat event rejected: %v", err)
+	}
+}
+
+func TestEventLimitsApplyToEverySendPath(t *testing.T) {
+	c := NewClient(ClientConfig{URL: "ws://0.0.0.0:1", MaxEventBytes: 64})
+	long := strings.Repeat("x", 100)
+	if err := c.SendRaw([]byte(`{"type":"console","message":"` + long + `"}`)); !errors.Is(err, ErrEventTooLarge) {
+		t.Fatalf("raw event returned %v", err)
+	}
+	if err := c.SendConsoleLines("info", []string{long}); !errors.Is(err, ErrEventTooLarge) {
+		t.Fatalf("console lines returned %v", err)
+	}
+	if n := c.buffer.len(); n != 0 {
+		t.Fatalf("buffered %d events", n)
+	}
+}
@@ -0,0 +1,45 @@
+package ariabridge
+
+import "encoding/json"
+
+// RegisterServerMessage decodes server messages of the given type into T and
+// passes them to handler. Built-in protocol types are never dispatched here.
+func RegisterServerMessage[T any](c *Client, typ string, handler func(T)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.serverHandlers == nil {
+		c.serverHandlers = map[string]func([]byte) error{}
+	}
+	c.serverHandlers[typ] = func(data []byte) error {
+		var v T
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		handler(v)
+		return nil
+	}
+}
+
+// OnServerMessage sets the fallback for server message types that have no
+// registered decoder.
+func (c *Client) OnServerMessage(handler func(map[string]any)) {
+	c.mu.Lock()
+	c.serverFallback = handler
+	c.mu.Unlock()
+}
+
+func (c *Client) dispatchServerMessage(typ string, data []byte, m map[string]any) {
+	c.mu.Lock()
+	decode := c.serverHandlers[typ]
+	fallback := c.serverFallback
+	c.mu.Unlock()
+	if decode != nil {
+		if err := decode(data); err != nil {
+			c.log("decode " + typ + ": " + err.Error())
+		}
+		return
+	}
+	if fallback != nil {
+		fallback(m)
+	}
+}
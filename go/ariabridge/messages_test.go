@@ -0,0 +1,49 @@
+package ariabridge
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type deployNotice struct {
+	Type    string `json:"type"`
+	Version string `json:"version"`
+	Canary  bool   `json:"canary"`
+}
+
+func TestRegisterServerMessageDecodesTyped(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret"})
+	got := make(chan deployNotice, 1)
+	RegisterServerMessage(c, "deploy", func(n deployNotice) { got <- n })
+	fallback := make(chan map[string]any, 1)
+	c.OnServerMessage(func(m map[string]any) { fallback <- m })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+
+	waitFor(t, func() bool { return len(h.ofType("hello")) == 1 }, time.Second)
+	h.sendJSON(t, map[string]any{"type": "deploy", "version": "1.2.3", "canary": true})
+	h.sendJSON(t, map[string]any{"type": "mystery", "n": 1})
+
+	select {
+	case n := <-got:
+		if n.Version != "1.2.3" || !n.Canary {
+			t.Fatalf("decoded %+v", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("typed handler not called")
+	}
+	select {
+	case m := <-fallback:
+		if m["type"] != "mystery" {
+			t.Fatalf("fallback got %v", m)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("fallback not called")
+	}
+}
@@ -0,0 +1,176 @@
+package ariabridge
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+const metricsIntervalDefault = 10 * time.Second
+
+type metricAgg struct {
+	Name  string            `json:"name"`
+	Value float64           `json:"value"`
+	Count int               `json:"count"`
+	Tags  map[string]string `json:"tags,omitempty"`
+}
+
+// SendMetric adds value to the counter identified by name and tags. Counters
+// are flushed as one batched metrics event every MetricsInterval. A ReadOnly
+// client could never flush them, so it returns ErrReadOnly without
+// aggregating.
+func (c *Client) SendMetric(name string, value float64, tags map[string]string) error {
+	if err := c.checkWritable(nil); err != nil {
+		return err
+	}
+	key := metricKey(name, tags)
+	c.metricsMu.Lock()
+	defer c.metricsMu.Unlock()
+	if c.metrics == nil {
+		c.metrics = map[string]*metricAgg{}
+	}
+	agg, ok := c.metrics[key]
+	if !ok {
+		copied := make(map[string]string, len(tags))
+		for k, v := range tags {
+			copied[k] = v
+		}
+		agg = &metricAgg{Name: name, Tags: copied}
+		c.metrics[key] = agg
+	}
+	agg.Value += value
+	agg.Count++
+	return nil
+}
+
+func metricKey(name string, tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString(name)
+	for _, k := range keys {
+		b.WriteString("|" + k + "=" + tags[k])
+	}
+	return b.String()
+}
+
+// FlushMetrics sends the aggregated counters now, if there are any.
+func (c *Client) FlushMetrics() error {
+	c.metricsMu.Lock()
+	pending := c.metrics
+	c.metrics = nil
+	c.metricsMu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(pending))
+	for k := range pending {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	batch := make([]*metricAgg, 0, len(keys))
+	for _, k := range keys {
+		batch = append(batch, pending[k])
+	}
+
+	if !c.featureEnabled(FeatureBatching) {
+		var errs []error
+		for _, m := range batch {
+			errs = append(errs, c.SendEvent(map[string]any{
+				"type":      "metric",
+				"name":      m.Name,
+				"value":     m.Value,
+				"count":     m.Count,
+				"tags":      m.Tags,
+				"timestamp": time.Now().UnixMilli(),
+			}))
+		}
+		return errors.Join(errs...)
+	}
+
+	ev := map[string]any{"type": "metrics", "timestamp": time.Now().UnixMilli()}
+	if !c.cfg.MetricsGzip {
+		ev["metrics"] = batch
+		return c.SendEvent(ev)
+	}
+	payload, err := gzipJSON(batch)
+	if err != nil {
+		return err
+	}
+	ev["encoding"] = "gzip"
+	ev["payload"] = payload
+	return c.SendEvent(ev)
+}
+
+// Gatherer writes a metrics exposition in OpenMetrics text format. The
+// package doc shows an adapter for a prometheus.Gatherer.
+type Gatherer interface {
+	Gather(w io.Writer) error
+}
+
+// GathererFunc adapts a function to Gatherer.
+type GathererFunc func(w io.Writer) error
+
+func (f GathererFunc) Gather(w io.Writer) error { return f(w) }
+
+// SendMetricsSnapshot sends the exposition g writes as one metrics event for
+// a collector on the server side. Its "format" of "openmetrics" sets it apart
+// from the batched counters FlushMetrics sends. The client must advertise the
+// "openmetrics" capability.
+func (c *Client) SendMetricsSnapshot(g Gatherer) error {
+	if !c.hasCapability("openmetrics") {
+		return fmt.Errorf("%w: metrics snapshot requires %q", ErrCapability, "openmetrics")
+	}
+	var buf bytes.Buffer
+	if err := g.Gather(&buf); err != nil {
+		return fmt.Errorf("gather metrics: %w", err)
+	}
+	return c.SendEvent(map[string]any{
+		"type":      "metrics",
+		"format":    "openmetrics",
+		"payload":   buf.String(),
+		"timestamp": time.Now().UnixMilli(),
+	})
+}
+
+func gzipJSON(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+func (c *Client) metricsLoop(ctx context.Context) {
+	ticker := time.NewTicker(c.cfg.MetricsInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.FlushMetrics(); err != nil {
+				c.log("metrics flush: " + err.Error())
+			}
+		}
+	}
+}
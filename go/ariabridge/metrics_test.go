@@ -0,0 +1,126 @@
+package ariabridge
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func startedClient(t *testing.T, h *harness, cfg ClientConfig) (*Client, context.CancelFunc) {
+	t.Helper()
+	cfg.URL = h.url
+	cfg.Secret = "dev-secret"
+	c := NewClient(cfg)
+	ctx, cancel := context.WithCancel(context.Background())
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+	return c, cancel
+}
+
+func TestSendMetricAggregatesCounters(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	c, cancel := startedClient(t, h, ClientConfig{MetricsInterval: time.Hour})
+	defer cancel()
+
+	c.SendMetric("requests", 1, map[string]string{"route": "/a", "code": "200"})
+	c.SendMetric("requests", 2, map[string]string{"code": "200", "route": "/a"})
+	c.SendMetric("requests", 1, map[string]string{"route": "/b", "code": "200"})
+	if err := c.FlushMetrics(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	waitFor(t, func() bool { return len(h.ofType("metrics")) == 1 }, time.Second)
+	batch := h.ofType("metrics")[0]["metrics"].([]any)
+	if len(batch) != 2 {
+		t.Fatalf("batch %v", batch)
+	}
+	first := batch[0].(map[string]any)
+	if first["name"] != "requests" || first["value"] != float64(3) || first["count"] != float64(2) {
+		t.Fatalf("aggregated %v", first)
+	}
+}
+
+func TestMetricsFlushOnInterval(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	c, cancel := startedClient(t, h, ClientConfig{MetricsInterval: 40 * time.Millisecond})
+	defer cancel()
+
+	c.SendMetric("ticks", 1, nil)
+	waitFor(t, func() bool { return len(h.ofType("metrics")) == 1 }, time.Second)
+	time.Sleep(100 * time.Millisecond)
+	if n := len(h.ofType("metrics")); n != 1 {
+		t.Fatalf("empty intervals produced %d batches", n)
+	}
+	c.SendMetric("ticks", 1, nil)
+	waitFor(t, func() bool { return len(h.ofType("metrics")) == 2 }, time.Second)
+}
+
+func TestMetricsGzipPayload(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	c, cancel := startedClient(t, h, ClientConfig{MetricsInterval: time.Hour, MetricsGzip: true})
+	defer cancel()
+
+	c.SendMetric("bytes", 512, map[string]string{"dir": "out"})
+	_ = c.FlushMetrics()
+	waitFor(t, func() bool { return len(h.ofType("metrics")) == 1 }, time.Second)
+
+	ev := h.ofType("metrics")[0]
+	if ev["encoding"] != "gzip" {
+		t.Fatalf("encoding %v", ev["encoding"])
+	}
+	raw, _ := base64.StdEncoding.DecodeString(ev["payload"].(string))
+	zr, err := gzip.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	data, _ := io.ReadAll(zr)
+	var batch []metricAgg
+	if err := json.Unmarshal(data, &batch); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(batch) != 1 || batch[0].Value != 512 || batch[0].Tags["dir"] != "out" {
+		t.Fatalf("batch %+v", batch)
+	}
+}
+
+func TestSendMetricsSnapshotCarriesExposition(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	c, cancel := startedClient(t, h, ClientConfig{Capabilities: []string{"console", "openmetrics"}})
+	defer cancel()
+
+	const exposition = "# TYPE jobs counter\njobs_total{queue=\"a\"} 3\n# EOF\n"
+	err := c.SendMetricsSnapshot(GathererFunc(func(w io.Writer) error {
+		_, err := io.WriteString(w, exposition)
+		return err
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return len(h.ofType("metrics")) == 1 }, time.Second)
+	m := h.ofType("metrics")[0]
+	if m["format"] != "openmetrics" || m["payload"] != exposition || m["metrics"] != nil {
+		t.Fatalf("event %v", m)
+	}
+}
+
+func TestSendMetricsSnapshotNeedsCapability(t *testing.T) {
+	c := NewClient(ClientConfig{URL: "ws://0.0.0.0:1", Secret: "dev-secret"})
+	gathered := false
+	err := c.SendMetricsSnapshot(GathererFunc(func(io.Writer) error {
+		gathered = true
+		return nil
+	}))
+	if !errors.Is(err, ErrCapability) || gathered {
+		t.Fatalf("err %v, gathered %v", err, gathered)
+	}
+}
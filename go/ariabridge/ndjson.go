@@ -0,0 +1,20 @@
+package ariabridge
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// ndjsonLine compacts data to a single JSON value on one line and appends
+// the trailing newline expected by servers that split the stream on '\n'.
+// Input holding more than one value is rejected.
+func ndjsonLine(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(len(data) + 1)
+	if err := json.Compact(&buf, data); err != nil {
+		return nil, fmt.Errorf("ndjson frame: %w", err)
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,53 @@
+package ariabridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestNDJSONFraming(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{
+		URL:           h.url,
+		Secret:        "dev-secret",
+		NDJSONFraming: true,
+		Marshal: func(v any) ([]byte, error) {
+			return json.MarshalIndent(v, "", "  ")
+		},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	_ = c.SendConsole("info", "line one\nline two")
+	_ = c.SendRaw([]byte("{\n  \"type\": \"console\",\n  \"message\": \"raw\"\n}"))
+	waitFor(t, func() bool { return len(h.ofType("console")) == 2 }, time.Second)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, frame := range h.raw {
+		if !bytes.HasSuffix(frame, []byte("\n")) || bytes.Count(frame, []byte("\n")) != 1 {
+			t.Fatalf("frame is not one newline-terminated line: %q", frame)
+		}
+		dec := json.NewDecoder(bytes.NewReader(frame))
+		var v map[string]any
+		if err := dec.Decode(&v); err != nil {
+			t.Fatalf("frame %q: %v", frame, err)
+		}
+		if dec.More() {
+			t.Fatalf("frame holds more than one object: %q", frame)
+		}
+	}
+}
+
+func TestNDJSONRejectsMultipleValues(t *testing.T) {
+	if _, err := ndjsonLine([]byte(`{"a":1}{"b":2}`)); err == nil {
+		t.Fatalf("expected error for two objects in one frame")
+	}
+}
@@ -0,0 +1,27 @@
+package ariabridge
+
+import "context"
+
+// Pipe enqueues every event received on events until ctx is done or events
+// is closed. The events are handed over, not copied, so producers must not
+// touch a map after sending it. Events without a type and enqueue errors are
+// logged and skipped.
+func (c *Client) Pipe(ctx context.Context, events <-chan map[string]any) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-events:
+			if !ok {
+				return
+			}
+			if t, _ := ev["type"].(string); t == "" {
+				c.log("pipe: event missing type")
+				continue
+			}
+			if err := c.enqueue(ev); err != nil {
+				c.log("pipe: " + err.Error())
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package ariabridge
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPipeDeliversChannelEvents(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+
+	events := make(chan map[string]any)
+	done := make(chan struct{})
+	go func() {
+		c.Pipe(ctx, events)
+		close(done)
+	}()
+	for i := 0; i < 5; i++ {
+		events <- map[string]any{"type": "console", "level": "info", "message": "m" + itoa(i)}
+	}
+	events <- map[string]any{"message": "untyped"}
+	close(events)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Pipe did not return after the channel closed")
+	}
+
+	waitFor(t, func() bool { return len(h.ofType("console")) == 5 }, time.Second)
+	for i, m := range h.ofType("console") {
+		if m["message"] != "m"+itoa(i) {
+			t.Fatalf("event %d = %v", i, m["message"])
+		}
+	}
+}
+
+func TestPipeStopsOnContext(t *testing.T) {
+	c := NewClient(ClientConfig{URL: "ws://127.0.0.1:1", Secret: "dev-secret"})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		c.Pipe(ctx, make(chan map[string]any))
+		close(done)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Pipe ignored cancellation")
+	}
+}
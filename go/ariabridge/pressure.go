@@ -0,0 +1,50 @@
+package ariabridge
+
+type pressureWatch struct {
+	threshold float64
+	fn        func()
+	above     bool
+}
+
+// BufferPressure reports how full the offline buffer is, from 0 to 1.
+func (c *Client) BufferPressure() float64 {
+	c.bufMu.Lock()
+	defer c.bufMu.Unlock()
+	if c.buffer.cap() == 0 {
+		return 0
+	}
+	return float64(c.buffer.len()) / float64(c.buffer.cap())
+}
+
+// OnPressure calls fn each time BufferPressure rises to threshold or above.
+// It re-arms once pressure falls back below the threshold.
+func (c *Client) OnPressure(threshold float64, fn func()) {
+	c.mu.Lock()
+	c.pressure = append(c.pressure, &pressureWatch{threshold: threshold, fn: fn})
+	c.mu.Unlock()
+}
+
+func (c *Client) checkPressure() {
+	c.mu.Lock()
+	if len(c.pressure) == 0 {
+		c.mu.Unlock()
+		return
+	}
+	c.mu.Unlock()
+	p := c.BufferPressure()
+	var fire []func()
+	c.mu.Lock()
+	for _, w := range c.pressure {
+		switch {
+		case p >= w.threshold && !w.above:
+			w.above = true
+			fire = append(fire, w.fn)
+		case p < w.threshold:
+			w.above = false
+		}
+	}
+	c.mu.Unlock()
+	for _, fn := range fire {
+		fn()
+	}
+}
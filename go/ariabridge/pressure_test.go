@@ -0,0 +1,51 @@
+package ariabridge
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBufferPressureAndCallback(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", BufferLimit: 4})
+	var fired atomic.Int32
+	c.OnPressure(0.75, func() { fired.Add(1) })
+
+	_ = c.SendConsole("info", "m0")
+	_ = c.SendConsole("info", "m1")
+	if p := c.BufferPressure(); p != 0.5 {
+		t.Fatalf("pressure %v", p)
+	}
+	if fired.Load() != 0 {
+		t.Fatalf("callback fired below threshold")
+	}
+	_ = c.SendConsole("info", "m2")
+	_ = c.SendConsole("info", "m3")
+	_ = c.SendConsole("info", "m4")
+	if p := c.BufferPressure(); p != 1 {
+		t.Fatalf("pressure %v", p)
+	}
+	if fired.Load() != 1 {
+		t.Fatalf("callback fired %d times crossing once", fired.Load())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.BufferPressure() == 0 }, time.Second)
+
+	h.mu.Lock()
+	_ = h.conn.Close()
+	h.mu.Unlock()
+	waitFor(t, func() bool { return c.State() != StateConnected }, time.Second)
+	for i := 0; i < 3; i++ {
+		_ = c.SendConsole("info", "again")
+	}
+	if fired.Load() != 2 {
+		t.Fatalf("callback did not re-arm after drain: fired %d", fired.Load())
+	}
+}
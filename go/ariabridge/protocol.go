@@ -0,0 +1,54 @@
+package ariabridge
+
+// Wire messages exchanged with a bridge host. Events the client buffers are
+// kept as maps so they can be enriched; these types document the fixed shapes.
+
+type AuthMessage struct {
+	Type      string `json:"type"`
+	Secret    string `json:"secret,omitempty"`
+	Role      string `json:"role"`
+	Nonce     string `json:"nonce,omitempty"`
+	Timestamp int64  `json:"timestamp,omitempty"`
+	Signature string `json:"signature,omitempty"`
+}
+
+type HelloMessage struct {
+	Type         string            `json:"type"`
+	Capabilities []string          `json:"capabilities"`
+	Platform     string            `json:"platform"`
+	ProjectID    string            `json:"projectId"`
+	Protocol     int               `json:"protocol"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	Features     []string          `json:"features,omitempty"`
+}
+
+type ConsoleEvent struct {
+	Type      string `json:"type"`
+	Level     string `json:"level"`
+	Message   string `json:"message"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+type ControlRequestMsg struct {
+	Type   string         `json:"type"`
+	ID     string         `json:"id"`
+	Action string         `json:"action"`
+	Args   map[string]any `json:"args,omitempty"`
+}
+
+type ControlResultMsg struct {
+	Type   string        `json:"type"`
+	ID     string        `json:"id"`
+	OK     bool          `json:"ok"`
+	Result any           `json:"result,omitempty"`
+	Error  *ControlError `json:"error,omitempty"`
+}
+
+type ControlError struct {
+	Message string `json:"message"`
+}
+
+// PingMessage is used for both ping and pong frames.
+type PingMessage struct {
+	Type string `json:"type"`
+}
@@ -0,0 +1,41 @@
+package ariabridge
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestWireMessagesMarshal(t *testing.T) {
+	cases := []struct {
+		msg  any
+		want string
+	}{
+		{AuthMessage{Type: "auth", Secret: "dev-secret", Role: "bridge"}, `{"type":"auth","secret":"dev-secret","role":"bridge"}`},
+		{AuthMessage{Type: "auth", Role: "bridge", Nonce: "n1", Timestamp: 5, Signature: "abc"}, `{"type":"auth","role":"bridge","nonce":"n1","timestamp":5,"signature":"abc"}`},
+		{HelloMessage{Type: "hello", Capabilities: []string{"console"}, Platform: "go", ProjectID: "p1", Protocol: ProtocolVersion}, `{"type":"hello","capabilities":["console"],"platform":"go","projectId":"p1","protocol":2}`},
+		{ConsoleEvent{Type: "console", Level: "info", Message: "hi", Timestamp: 1}, `{"type":"console","level":"info","message":"hi","timestamp":1}`},
+		{ControlRequestMsg{Type: "control_request", ID: "c1", Action: "reload"}, `{"type":"control_request","id":"c1","action":"reload"}`},
+		{ControlResultMsg{Type: "control_result", ID: "c1", OK: true, Result: map[string]any{"echo": true}}, `{"type":"control_result","id":"c1","ok":true,"result":{"echo":true}}`},
+		{ControlResultMsg{Type: "control_result", ID: "c2", Error: &ControlError{Message: "boom"}}, `{"type":"control_result","id":"c2","ok":false,"error":{"message":"boom"}}`},
+		{PingMessage{Type: "ping"}, `{"type":"ping"}`},
+	}
+	for _, tc := range cases {
+		data, err := json.Marshal(tc.msg)
+		if err != nil {
+			t.Fatalf("marshal %T: %v", tc.msg, err)
+		}
+		if string(data) != tc.want {
+			t.Fatalf("%T marshaled to %s, want %s", tc.msg, data, tc.want)
+		}
+	}
+}
+
+func TestControlRequestMsgDecodes(t *testing.T) {
+	var req ControlRequestMsg
+	if err := json.Unmarshal([]byte(`{"type":"control_request","id":"c1","action":"reload","args":{"hard":true}}`), &req); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if req.ID != "c1" || req.Action != "reload" || req.Args["hard"] != true {
+		t.Fatalf("decoded %+v", req)
+	}
+}
@@ -0,0 +1,89 @@
+package ariabridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// pendingQuery receives the server's responses to one client-initiated
+// request. done is closed when the requester stops listening.
+type pendingQuery struct {
+	pages chan map[string]any
+	done  chan struct{}
+}
+
+// RequestServerLogs asks the server for the logs it holds since the given
+// time and returns them once the last page has arrived. The server answers
+// with "logs" messages carrying the request id, a "logs" array and "done"
+// set on the final page.
+func (c *Client) RequestServerLogs(ctx context.Context, since time.Time) ([]map[string]any, error) {
+	var logs []map[string]any
+	err := c.query(ctx, map[string]any{"type": "get_logs", "since": since.UnixMilli()}, func(page map[string]any) bool {
+		entries, _ := page["logs"].([]any)
+		for _, e := range entries {
+			if m, ok := e.(map[string]any); ok {
+				logs = append(logs, m)
+			}
+		}
+		done, _ := page["done"].(bool)
+		return done
+	})
+	if err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+// query sends req with a fresh id and passes each correlated response to
+// page until it reports the exchange complete.
+func (c *Client) query(ctx context.Context, req map[string]any, page func(map[string]any) bool) error {
+	id := fmt.Sprintf("q-%d", c.querySeq.Add(1))
+	pq := &pendingQuery{pages: make(chan map[string]any, 1), done: make(chan struct{})}
+	c.mu.Lock()
+	if c.queries == nil {
+		c.queries = map[string]*pendingQuery{}
+	}
+	c.queries[id] = pq
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.queries, id)
+		c.mu.Unlock()
+		close(pq.done)
+	}()
+
+	req["id"] = id
+	if err := c.send(req); err != nil {
+		if errors.Is(err, errNotConnected) {
+			c.wakeIdle()
+		}
+		return fmt.Errorf("%s: %w", req["type"], err)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case m := <-pq.pages:
+			if page(m) {
+				return nil
+			}
+		}
+	}
+}
+
+// deliverQuery hands a response to the request it answers, if still waiting.
+func (c *Client) deliverQuery(m map[string]any) {
+	id, _ := m["id"].(string)
+	c.mu.Lock()
+	pq := c.queries[id]
+	c.mu.Unlock()
+	if pq == nil {
+		return
+	}
+	select {
+	case pq.pages <- m:
+	case <-pq.done:
+	}
+}
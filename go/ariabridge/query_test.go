@@ -0,0 +1,69 @@
+package ariabridge
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestRequestServerLogsAssemblesPages(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	since := time.UnixMilli(1700000000000)
+	h.onMessage(func(conn *websocket.Conn, m map[string]any) {
+		if m["type"] != "get_logs" {
+			return
+		}
+		if m["since"] != float64(since.UnixMilli()) {
+			return
+		}
+		_ = conn.WriteJSON(map[string]any{"type": "logs", "id": "other", "logs": []any{map[string]any{"message": "stray"}}, "done": true})
+		for i, page := range [][]string{{"a", "b"}, {"c"}, {}} {
+			logs := []any{}
+			for _, msg := range page {
+				logs = append(logs, map[string]any{"message": msg})
+			}
+			_ = conn.WriteJSON(map[string]any{"type": "logs", "id": m["id"], "logs": logs, "done": i == 2})
+		}
+	})
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	qctx, qcancel := context.WithTimeout(ctx, time.Second)
+	defer qcancel()
+	logs, err := c.RequestServerLogs(qctx, since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 3 || logs[0]["message"] != "a" || logs[2]["message"] != "c" {
+		t.Fatalf("logs %v", logs)
+	}
+}
+
+func TestRequestServerLogsHonoursContext(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	qctx, qcancel := context.WithTimeout(ctx, 30*time.Millisecond)
+	defer qcancel()
+	if _, err := c.RequestServerLogs(qctx, time.Now()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v", err)
+	}
+	c.mu.Lock()
+	n := len(c.queries)
+	c.mu.Unlock()
+	if n != 0 {
+		t.Fatalf("%d queries left registered", n)
+	}
+}
@@ -0,0 +1,25 @@
+package ariabridge
+
+const readOnlyCapability = "readonly"
+
+// readOnlyCapabilities is what a ReadOnly client advertises: the configured
+// capabilities, or none, plus "readonly" so the server knows not to expect
+// events.
+func readOnlyCapabilities(caps []string) []string {
+	out := make([]string, 0, len(caps)+1)
+	for _, cp := range caps {
+		if cp != readOnlyCapability {
+			out = append(out, cp)
+		}
+	}
+	return append(out, readOnlyCapability)
+}
+
+// checkWritable rejects events from a ReadOnly client. Control results are
+// still allowed since answering control requests is all such a client does.
+func (c *Client) checkWritable(ev map[string]any) error {
+	if !c.cfg.ReadOnly || ev["type"] == "control_result" {
+		return nil
+	}
+	return ErrReadOnly
+}
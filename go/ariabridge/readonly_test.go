@@ -0,0 +1,49 @@
+package ariabridge
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReadOnlyRejectsEventsButAnswersControl(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", ReadOnly: true})
+	c.OnControl(func(msg map[string]any) (any, error) {
+		return "done", nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	if err := c.SendConsole("info", "nope"); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("SendConsole returned %v", err)
+	}
+	if err := c.SendConsoleLines("info", []string{"a"}); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("SendConsoleLines returned %v", err)
+	}
+	if err := c.SendMetric("requests", 1, nil); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("SendMetric returned %v", err)
+	}
+	c.metricsMu.Lock()
+	aggregated := len(c.metrics)
+	c.metricsMu.Unlock()
+	if aggregated != 0 {
+		t.Fatalf("read-only client aggregated %d metrics", aggregated)
+	}
+	h.sendControlRequest(t, "ro-1", "status")
+	waitFor(t, func() bool { return len(h.ofType("control_result")) == 1 }, time.Second)
+	if m := h.ofType("control_result")[0]; m["id"] != "ro-1" || m["result"] != "done" {
+		t.Fatalf("control result %v", m)
+	}
+	if n := len(h.ofType("console")); n != 0 {
+		t.Fatalf("read-only client sent %d console events", n)
+	}
+	caps := h.ofType("hello")[0]["capabilities"].([]any)
+	if len(caps) != 1 || caps[0] != "readonly" {
+		t.Fatalf("hello capabilities %v", caps)
+	}
+}
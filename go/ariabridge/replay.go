@@ -0,0 +1,60 @@
+package ariabridge
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+const replayLineMax = 1 << 20
+
+// Replay reads JSON-lines events from r and enqueues them in order. When
+// realtime is set, the gap between consecutive event timestamps is honored.
+func (c *Client) Replay(ctx context.Context, r io.Reader, realtime bool) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), replayLineMax)
+	var last int64
+	line := 0
+	for sc.Scan() {
+		line++
+		data := sc.Bytes()
+		if len(data) == 0 {
+			continue
+		}
+		var ev map[string]any
+		if err := json.Unmarshal(data, &ev); err != nil {
+			return fmt.Errorf("replay line %d: %w", line, err)
+		}
+		if realtime {
+			if ts, ok := ev["timestamp"].(float64); ok {
+				if last != 0 && int64(ts) > last {
+					if err := sleepCtx(ctx, time.Duration(int64(ts)-last)*time.Millisecond); err != nil {
+						return err
+					}
+				}
+				last = int64(ts)
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := c.enqueue(ev); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,52 @@
+package ariabridge
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReplayPreservesOrder(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+
+	waitFor(t, func() bool { return len(h.ofType("hello")) == 1 }, time.Second)
+
+	capture := strings.Join([]string{
+		`{"type":"console","level":"info","message":"r0","timestamp":1000}`,
+		``,
+		`{"type":"console","level":"warn","message":"r1","timestamp":1020}`,
+		`{"type":"console","level":"error","message":"r2","timestamp":1040}`,
+	}, "\n")
+	start := time.Now()
+	if err := c.Replay(ctx, strings.NewReader(capture), true); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Fatalf("realtime replay finished in %v", elapsed)
+	}
+
+	waitFor(t, func() bool { return len(h.ofType("console")) == 3 }, time.Second)
+	var got []string
+	for _, m := range h.ofType("console") {
+		got = append(got, m["message"].(string))
+	}
+	if !reflect.DeepEqual([]string{"r0", "r1", "r2"}, got) {
+		t.Fatalf("replayed %v", got)
+	}
+}
+
+func TestReplayRejectsMalformedLine(t *testing.T) {
+	c := NewClient(ClientConfig{URL: "ws://0.0.0.0:1", Secret: "dev-secret"})
+	err := c.Replay(context.Background(), strings.NewReader("{\"type\":\"console\"}\nnot-json\n"), false)
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("err %v", err)
+	}
+}
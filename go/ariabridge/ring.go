@@ -0,0 +1,92 @@
+package ariabridge
+
+// ring is a fixed-capacity FIFO of queued events that evicts the oldest entry
+// on overflow without reallocating.
+type ring struct {
+	items []queued
+	head  int
+	n     int
+}
+
+func newRing(capacity int) *ring {
+	if capacity < 0 {
+		capacity = 0
+	}
+	return &ring{items: make([]queued, capacity)}
+}
+
+func (r *ring) len() int { return r.n }
+
+func (r *ring) cap() int { return len(r.items) }
+
+// push appends q, evicting the oldest entry when full. It reports whether an
+// entry was evicted.
+func (r *ring) push(q queued) bool {
+	if len(r.items) == 0 {
+		return true
+	}
+	if r.n == len(r.items) {
+		r.items[r.head] = q
+		r.head = (r.head + 1) % len(r.items)
+		return true
+	}
+	r.items[(r.head+r.n)%len(r.items)] = q
+	r.n++
+	return false
+}
+
+// pushFront puts q back ahead of every entry. A full ring keeps what it
+// holds and reports q as evicted, since q is the oldest.
+func (r *ring) pushFront(q queued) bool {
+	if r.n == len(r.items) {
+		return true
+	}
+	r.head = (r.head - 1 + len(r.items)) % len(r.items)
+	r.items[r.head] = q
+	r.n++
+	return false
+}
+
+func (r *ring) peek() queued {
+	return r.items[r.head]
+}
+
+// pop removes and returns the oldest entry.
+func (r *ring) pop() (queued, bool) {
+	if r.n == 0 {
+		return queued{}, false
+	}
+	q := r.items[r.head]
+	r.items[r.head] = queued{}
+	r.head = (r.head + 1) % len(r.items)
+	r.n--
+	return q, true
+}
+
+// at returns the i-th oldest entry.
+func (r *ring) at(i int) queued {
+	return r.items[(r.head+i)%len(r.items)]
+}
+
+// removeAt removes the i-th oldest entry, moving the newer ones up.
+func (r *ring) removeAt(i int) queued {
+	q := r.at(i)
+	for ; i < r.n-1; i++ {
+		r.items[(r.head+i)%len(r.items)] = r.at(i + 1)
+	}
+	r.items[(r.head+r.n-1)%len(r.items)] = queued{}
+	r.n--
+	return q
+}
+
+// drain returns the buffered entries oldest first and empties the ring.
+func (r *ring) drain() []queued {
+	out := make([]queued, 0, r.n)
+	for i := 0; i < r.n; i++ {
+		idx := (r.head + i) % len(r.items)
+		out = append(out, r.items[idx])
+		r.items[idx] = queued{}
+	}
+	r.head, r.n = 0, 0
+	return out
+}
@@ -0,0 +1,83 @@
+package ariabridge
+
+import (
+	"reflect"
+	"testing"
+)
+
+func ringMessages(qs []queued) []any {
+	var out []any
+	for _, q := range qs {
+		out = append(out, q.ev["message"])
+	}
+	return out
+}
+
+func TestRingEvictsOldest(t *testing.T) {
+	r := newRing(3)
+	evicted := 0
+	for i := 0; i < 5; i++ {
+		if r.push(queued{ev: map[string]any{"message": i}}) {
+			evicted++
+		}
+	}
+	if evicted != 2 || r.len() != 3 {
+		t.Fatalf("evicted %d len %d", evicted, r.len())
+	}
+	if got := ringMessages(r.drain()); !reflect.DeepEqual([]any{2, 3, 4}, got) {
+		t.Fatalf("drained %v", got)
+	}
+	if r.len() != 0 {
+		t.Fatalf("ring not empty after drain")
+	}
+	r.push(queued{ev: map[string]any{"message": 5}})
+	if got := ringMessages(r.drain()); !reflect.DeepEqual([]any{5}, got) {
+		t.Fatalf("drained after reuse %v", got)
+	}
+}
+
+func TestRingPushFront(t *testing.T) {
+	r := newRing(3)
+	r.push(queued{ev: map[string]any{"message": 2}})
+	r.pushFront(queued{ev: map[string]any{"message": 1}})
+	r.push(queued{ev: map[string]any{"message": 3}})
+	if !r.pushFront(queued{ev: map[string]any{"message": 0}}) {
+		t.Fatalf("full ring accepted an entry at the front")
+	}
+	if got := ringMessages(r.drain()); !reflect.DeepEqual([]any{1, 2, 3}, got) {
+		t.Fatalf("drained %v", got)
+	}
+}
+
+func BenchmarkRingOverflow(b *testing.B) {
+	r := newRing(bufferLimitDefault)
+	q := queued{ev: map[string]any{"type": "console", "message": "x"}}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.push(q)
+	}
+}
+
+func BenchmarkEnqueueOfflineOverflow(b *testing.B) {
+	c := NewClient(ClientConfig{URL: "ws://0.0.0.0:1", BufferLimit: 50})
+	ev := map[string]any{"type": "console", "level": "info", "message": "x"}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = c.enqueue(ev)
+	}
+}
+
+func TestRingPop(t *testing.T) {
+	r := newRing(2)
+	r.push(queued{ev: map[string]any{"message": 0}})
+	r.push(queued{ev: map[string]any{"message": 1}})
+	r.push(queued{ev: map[string]any{"message": 2}})
+	q, ok := r.pop()
+	if !ok || q.ev["message"] != 1 || r.len() != 1 {
+		t.Fatalf("pop %v %v len %d", q.ev, ok, r.len())
+	}
+	r.pop()
+	if _, ok := r.pop(); ok {
+		t.Fatalf("pop from empty ring")
+	}
+}
@@ -0,0 +1,15 @@
+package ariabridge
+
+// route applies LevelRouter to console events. An empty type from the router
+// keeps the event on the console channel.
+func (c *Client) route(q *queued) {
+	if c.cfg.LevelRouter == nil || q.ev["type"] != "console" {
+		return
+	}
+	level, _ := q.ev["level"].(string)
+	typ, cp := c.cfg.LevelRouter(level)
+	if typ != "" {
+		q.ev["type"] = typ
+	}
+	q.cp = cp
+}
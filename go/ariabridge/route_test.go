@@ -0,0 +1,55 @@
+package ariabridge
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLevelRouterSplitsConsole(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{
+		URL:    h.url,
+		Secret: "dev-secret",
+		LevelRouter: func(level string) (string, string) {
+			if level == "error" {
+				return "error", "error"
+			}
+			return "", ""
+		},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+
+	_ = c.SendConsole("error", "boom")
+	_ = c.SendConsole("info", "fine")
+	_ = c.SendConsoleLines("error", []string{"batched"})
+	waitFor(t, func() bool { return len(h.ofType("error")) == 2 && len(h.ofType("console")) == 1 }, time.Second)
+	if m := h.ofType("error")[0]; m["message"] != "boom" || m["level"] != "error" {
+		t.Fatalf("error event %v", m)
+	}
+	if m := h.ofType("error")[1]; m["message"] != "batched" {
+		t.Fatalf("batched line %v", m)
+	}
+	if m := h.ofType("console")[0]; m["message"] != "fine" {
+		t.Fatalf("console event %v", m)
+	}
+}
+
+func TestLevelRouterCapabilityEnforced(t *testing.T) {
+	c := NewClient(ClientConfig{
+		URL:                "ws://127.0.0.1:1",
+		Secret:             "dev-secret",
+		StrictCapabilities: true,
+		LevelRouter: func(level string) (string, string) {
+			return "alert", "alerts"
+		},
+	})
+	if err := c.SendConsole("error", "boom"); !errors.Is(err, ErrCapability) {
+		t.Fatalf("err = %v", err)
+	}
+}
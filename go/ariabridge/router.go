@@ -0,0 +1,59 @@
+package ariabridge
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrUnknownAction = errors.New("unknown action")
+
+// ControlRequest is a control_request as seen by action handlers. Msg is the
+// whole decoded message.
+type ControlRequest struct {
+	ID     string
+	Action string
+	Args   map[string]any
+	Msg    map[string]any
+}
+
+// HandleAction routes control requests for action to handler. The first
+// registration installs the router as the control handler, replacing any set
+// with OnControl.
+func (c *Client) HandleAction(action string, handler func(req ControlRequest) (any, error)) {
+	c.editRouter(func() { c.actions[action] = handler })
+}
+
+// OnUnknownAction sets the catch-all for actions with no HandleAction
+// registration. Without one they fail with ErrUnknownAction.
+func (c *Client) OnUnknownAction(handler func(req ControlRequest) (any, error)) {
+	c.editRouter(func() { c.unknownAction = handler })
+}
+
+func (c *Client) editRouter(edit func()) {
+	c.mu.Lock()
+	install := c.actions == nil
+	if install {
+		c.actions = map[string]func(ControlRequest) (any, error){}
+	}
+	edit()
+	c.mu.Unlock()
+	if install {
+		c.OnControl(c.routeAction)
+	}
+}
+
+func (c *Client) routeAction(msg map[string]any) (any, error) {
+	req := ControlRequest{ID: controlID(msg), Msg: msg}
+	req.Action, _ = msg["action"].(string)
+	req.Args, _ = msg["args"].(map[string]any)
+	c.mu.Lock()
+	handler, ok := c.actions[req.Action]
+	if !ok {
+		handler = c.unknownAction
+	}
+	c.mu.Unlock()
+	if handler == nil {
+		return nil, fmt.Errorf("%w %q", ErrUnknownAction, req.Action)
+	}
+	return handler(req)
+}
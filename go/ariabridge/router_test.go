@@ -0,0 +1,57 @@
+package ariabridge
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestActionRouterCatchAll(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret"})
+	c.HandleAction("reload", func(req ControlRequest) (any, error) { return "reloaded", nil })
+	var unmatched []string
+	c.OnUnknownAction(func(req ControlRequest) (any, error) {
+		unmatched = append(unmatched, req.Action)
+		return "proxied " + req.Action, nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	h.sendControlRequest(t, "r1", "reload")
+	h.sendControlRequest(t, "r2", "restart")
+	waitFor(t, func() bool { return len(h.ofType("control_result")) == 2 }, time.Second)
+	results := map[any]any{}
+	for _, m := range h.ofType("control_result") {
+		results[m["id"]] = m["result"]
+	}
+	if results["r1"] != "reloaded" || results["r2"] != "proxied restart" {
+		t.Fatalf("results %v", results)
+	}
+	if len(unmatched) != 1 || unmatched[0] != "restart" {
+		t.Fatalf("catch-all saw %v", unmatched)
+	}
+}
+
+func TestActionRouterUnknownActionError(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret"})
+	c.HandleAction("reload", func(req ControlRequest) (any, error) { return nil, nil })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	h.sendControlRequest(t, "r1", "explode")
+	waitFor(t, func() bool { return len(h.ofType("control_result")) == 1 }, time.Second)
+	m := h.ofType("control_result")[0]
+	msg, _ := m["error"].(map[string]any)["message"].(string)
+	if m["ok"] != false || !strings.Contains(msg, `unknown action "explode"`) {
+		t.Fatalf("result %v", m)
+	}
+}
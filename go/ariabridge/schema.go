@@ -0,0 +1,220 @@
+package ariabridge
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+var ErrSchema = errors.New("event does not match schema")
+
+// SchemaValidator checks an outgoing event before it is sent. It gets the
+// fully stamped event as decoded from the bytes Marshal produces. Events it
+// rejects go to FallbackSink and the send returns an error wrapping
+// ErrSchema.
+type SchemaValidator interface {
+	Validate(ev map[string]any) error
+}
+
+// Schema is a compiled JSON Schema. Only the keywords most event contracts
+// need are supported: type, required, properties, additionalProperties (as a
+// boolean), enum and items. The annotations $schema, $id, title and
+// description are accepted and ignored; any other keyword is an error when
+// the schema is compiled. Use another SchemaValidator for full drafts.
+type Schema struct {
+	types                []string
+	required             []string
+	properties           map[string]*Schema
+	additionalProperties *bool
+	enum                 []any
+	items                *Schema
+}
+
+type schemaJSON struct {
+	Type                 json.RawMessage            `json:"type"`
+	Required             []string                   `json:"required"`
+	Properties           map[string]json.RawMessage `json:"properties"`
+	AdditionalProperties json.RawMessage            `json:"additionalProperties"`
+	Enum                 []any                      `json:"enum"`
+	Items                json.RawMessage            `json:"items"`
+
+	Dialect     json.RawMessage `json:"$schema"`
+	ID          json.RawMessage `json:"$id"`
+	Title       json.RawMessage `json:"title"`
+	Description json.RawMessage `json:"description"`
+}
+
+// CompileSchema parses a JSON Schema document.
+func CompileSchema(data []byte) (*Schema, error) {
+	var raw schemaJSON
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("schema: %w", err)
+	}
+	s := &Schema{required: raw.Required, enum: raw.Enum}
+	if len(raw.Type) > 0 {
+		var one string
+		if err := json.Unmarshal(raw.Type, &one); err == nil {
+			s.types = []string{one}
+		} else if err := json.Unmarshal(raw.Type, &s.types); err != nil {
+			return nil, fmt.Errorf("schema: type: %w", err)
+		}
+	}
+	if len(raw.AdditionalProperties) > 0 {
+		var b bool
+		if err := json.Unmarshal(raw.AdditionalProperties, &b); err != nil {
+			return nil, errors.New("schema: additionalProperties must be a boolean")
+		}
+		s.additionalProperties = &b
+	}
+	if len(raw.Properties) > 0 {
+		s.properties = make(map[string]*Schema, len(raw.Properties))
+		for name, sub := range raw.Properties {
+			p, err := CompileSchema(sub)
+			if err != nil {
+				return nil, fmt.Errorf("properties.%s: %w", name, err)
+			}
+			s.properties[name] = p
+		}
+	}
+	if len(raw.Items) > 0 {
+		items, err := CompileSchema(raw.Items)
+		if err != nil {
+			return nil, fmt.Errorf("items: %w", err)
+		}
+		s.items = items
+	}
+	return s, nil
+}
+
+// Validate checks ev as it will be encoded on the wire.
+func (s *Schema) Validate(ev map[string]any) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	return s.check("$", v)
+}
+
+func (s *Schema) check(path string, v any) error {
+	if len(s.types) > 0 && !matchesType(s.types, v) {
+		return fmt.Errorf("%s: want %s, got %s", path, strings.Join(s.types, " or "), jsonType(v))
+	}
+	if len(s.enum) > 0 && !inEnum(s.enum, v) {
+		return fmt.Errorf("%s: %v not in enum", path, v)
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		for _, name := range s.required {
+			if _, ok := v[name]; !ok {
+				return fmt.Errorf("%s: missing required field %q", path, name)
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			p, ok := s.properties[name]
+			if !ok {
+				if s.additionalProperties != nil && !*s.additionalProperties {
+					return fmt.Errorf("%s: unexpected field %q", path, name)
+				}
+				continue
+			}
+			if err := p.check(path+"."+name, v[name]); err != nil {
+				return err
+			}
+		}
+	case []any:
+		if s.items != nil {
+			for i, item := range v {
+				if err := s.items.check(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func jsonType(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+func matchesType(types []string, v any) bool {
+	got := jsonType(v)
+	for _, t := range types {
+		if t == got || (t == "number" && got == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func inEnum(enum []any, v any) bool {
+	want, err := json.Marshal(v)
+	if err != nil {
+		return false
+	}
+	for _, e := range enum {
+		if got, err := json.Marshal(e); err == nil && string(got) == string(want) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSchema runs SchemaValidator on events from the send APIs, passing
+// rejected events to FallbackSink. It runs once eventId, order and seq are
+// stamped and sees the event decoded from its wire encoding.
+func (c *Client) checkSchema(q queued) error {
+	if c.cfg.SchemaValidator == nil || q.ev["type"] == "control_result" {
+		return nil
+	}
+	data := q.raw
+	if data == nil {
+		var err error
+		if data, err = c.cfg.Marshal(q.ev); err != nil {
+			return err
+		}
+	}
+	var wire map[string]any
+	err := json.Unmarshal(data, &wire)
+	if err == nil {
+		err = c.cfg.SchemaValidator.Validate(wire)
+	}
+	if err == nil {
+		return nil
+	}
+	if c.cfg.FallbackSink != nil {
+		c.cfg.FallbackSink(q.ev)
+	}
+	return fmt.Errorf("%w: %v", ErrSchema, err)
+}
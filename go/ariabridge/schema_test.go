@@ -0,0 +1,89 @@
+package ariabridge
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const consoleSchema = `{
+	"type": "object",
+	"required": ["type", "level", "message", "service"],
+	"properties": {
+		"level": {"enum": ["debug", "info", "warn", "error"]},
+		"message": {"type": "string"},
+		"tags": {"type": "array", "items": {"type": "string"}}
+	}
+}`
+
+func TestSchemaValidatorRejectsToFallback(t *testing.T) {
+	schema, err := CompileSchema([]byte(consoleSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := &sinkRecorder{}
+	c := NewClient(ClientConfig{URL: "ws://0.0.0.0:1", SchemaValidator: schema, FallbackSink: rec.sink})
+
+	err = c.SendConsole("info", "no service")
+	if !errors.Is(err, ErrSchema) || !strings.Contains(err.Error(), `missing required field "service"`) {
+		t.Fatalf("invalid event returned %v", err)
+	}
+	if got := rec.messages(); len(got) != 1 || got[0] != "no service" {
+		t.Fatalf("fallback received %v", got)
+	}
+
+	svc := c.WithFields(map[string]any{"service": "api"})
+	if err := svc.SendConsole("info", "ok"); err != nil {
+		t.Fatalf("valid event rejected: %v", err)
+	}
+	if n := c.buffer.len(); n != 1 {
+		t.Fatalf("buffered %d events", n)
+	}
+}
+
+func TestSchemaChecksNestedValues(t *testing.T) {
+	schema, err := CompileSchema([]byte(consoleSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev := map[string]any{"type": "console", "level": "loud", "message": "m", "service": "api"}
+	if err := schema.Validate(ev); err == nil || !strings.Contains(err.Error(), "$.level") {
+		t.Fatalf("enum violation returned %v", err)
+	}
+	ev["level"] = "info"
+	ev["tags"] = []any{"a", 1}
+	if err := schema.Validate(ev); err == nil || !strings.Contains(err.Error(), "$.tags[1]: want string, got integer") {
+		t.Fatalf("items violation returned %v", err)
+	}
+}
+
+func TestCompileSchemaRejectsUnknownKeywords(t *testing.T) {
+	if _, err := CompileSchema([]byte(`{"$schema": "https://json-schema.org/draft/2020-12/schema", "title": "console", "type": "object"}`)); err != nil {
+		t.Fatalf("annotations rejected: %v", err)
+	}
+	_, err := CompileSchema([]byte(`{"type": "object", "properties": {"message": {"type": "string", "maxLength": 10}}}`))
+	if err == nil || !strings.Contains(err.Error(), "maxLength") {
+		t.Fatalf("unknown keyword returned %v", err)
+	}
+}
+
+func TestSchemaSeesStampedWireEvent(t *testing.T) {
+	schema, err := CompileSchema([]byte(`{"required": ["eventId", "order", "seq", "at"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	marshal := func(v any) ([]byte, error) {
+		ev := v.(map[string]any)
+		out := make(map[string]any, len(ev))
+		for k, val := range ev {
+			out[k] = val
+		}
+		out["at"] = "encoded"
+		return json.Marshal(out)
+	}
+	c := NewClient(ClientConfig{URL: "ws://0.0.0.0:1", SchemaValidator: schema, Marshal: marshal, ChainSequence: true})
+	if err := c.SendConsole("info", "stamped"); err != nil {
+		t.Fatalf("stamped event rejected: %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package ariabridge
+
+import "strings"
+
+type scope struct {
+	name   string
+	fields map[string]any
+}
+
+// PushScope opens a named scope whose fields are added to every event this
+// client sends until the matching PopScope. Scopes nest: inner fields win over
+// outer ones and the event gets a "scope" field joining the names with "/".
+//
+// The stack belongs to this *Client and is shared by every goroutine using
+// it. Goroutines that need their own scopes should push onto a child from
+// WithFields, which starts with an empty stack.
+func (c *Client) PushScope(name string, fields map[string]any) {
+	copied := make(map[string]any, len(fields))
+	for k, v := range fields {
+		copied[k] = v
+	}
+	c.scopeMu.Lock()
+	c.scopes = append(c.scopes, scope{name: name, fields: copied})
+	c.scopeMu.Unlock()
+}
+
+// PopScope closes the innermost scope. It does nothing when no scope is open.
+func (c *Client) PopScope() {
+	c.scopeMu.Lock()
+	if n := len(c.scopes); n > 0 {
+		c.scopes = c.scopes[:n-1]
+	}
+	c.scopeMu.Unlock()
+}
+
+func (c *Client) stampScopes(ev map[string]any) {
+	c.scopeMu.Lock()
+	defer c.scopeMu.Unlock()
+	if len(c.scopes) == 0 {
+		return
+	}
+	names := make([]string, len(c.scopes))
+	for i, s := range c.scopes {
+		names[i] = s.name
+	}
+	if _, ok := ev["scope"]; !ok {
+		ev["scope"] = strings.Join(names, "/")
+	}
+	for i := len(c.scopes) - 1; i >= 0; i-- {
+		for k, v := range c.scopes[i].fields {
+			if _, ok := ev[k]; !ok {
+				ev[k] = v
+			}
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package ariabridge
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestScopesAttachFieldsUntilPopped(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	c.PushScope("request", map[string]any{"requestId": "r1", "step": "outer"})
+	c.PushScope("db", map[string]any{"step": "query"})
+	_ = c.SendConsole("info", "inner")
+	c.PopScope()
+	_ = c.SendConsole("info", "outer")
+	c.PopScope()
+	c.PopScope()
+	_ = c.SendConsole("info", "none")
+
+	waitFor(t, func() bool { return len(h.ofType("console")) == 3 }, time.Second)
+	got := h.ofType("console")
+	if m := got[0]; m["scope"] != "request/db" || m["step"] != "query" || m["requestId"] != "r1" {
+		t.Fatalf("inner event %v", m)
+	}
+	if m := got[1]; m["scope"] != "request" || m["step"] != "outer" {
+		t.Fatalf("outer event %v", m)
+	}
+	if m := got[2]; m["scope"] != nil || m["requestId"] != nil {
+		t.Fatalf("event after pop kept scope fields: %v", m)
+	}
+}
+
+func TestWithFieldsChildHasOwnScopes(t *testing.T) {
+	c := NewClient(ClientConfig{URL: "ws://127.0.0.1:1", Secret: "dev-secret"})
+	c.PushScope("parent", nil)
+	child := c.WithFields(nil)
+	ev := map[string]any{}
+	child.stampFields(ev)
+	if _, ok := ev["scope"]; ok {
+		t.Fatalf("child inherited parent scope: %v", ev)
+	}
+}
@@ -0,0 +1,19 @@
+package ariabridge
+
+// UpdateSecret switches to newSecret. A live connection is closed gracefully
+// and re-established straight away so the next auth uses the new value.
+func (c *Client) UpdateSecret(newSecret string) {
+	c.mu.Lock()
+	c.cfg.Secret = newSecret
+	c.mu.Unlock()
+	select {
+	case c.reconnectCh <- struct{}{}:
+	default:
+	}
+}
+
+func (c *Client) secret() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cfg.Secret
+}
@@ -0,0 +1,51 @@
+package ariabridge
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestUpdateSecretReconnectsWithNewSecret(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "old-secret"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+	_ = c.SendConsole("info", "before")
+	waitFor(t, func() bool { return len(h.ofType("console")) == 1 }, time.Second)
+
+	c.UpdateSecret("new-secret")
+	waitFor(t, func() bool { return len(h.ofType("auth")) == 2 }, time.Second)
+	auths := h.ofType("auth")
+	if auths[0]["secret"] != "old-secret" || auths[1]["secret"] != "new-secret" {
+		t.Fatalf("auth secrets %v, %v", auths[0]["secret"], auths[1]["secret"])
+	}
+	h.mu.Lock()
+	header := h.header.Get("X-Bridge-Secret")
+	h.mu.Unlock()
+	if header != "new-secret" {
+		t.Fatalf("header secret %q", header)
+	}
+}
+
+func TestUpdateSecretWhileDisconnectedDoesNotRecycle(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "old-secret"})
+	c.UpdateSecret("new-secret")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+	time.Sleep(50 * time.Millisecond)
+
+	auths := h.ofType("auth")
+	if len(auths) != 1 || auths[0]["secret"] != "new-secret" {
+		t.Fatalf("auths %v", auths)
+	}
+}
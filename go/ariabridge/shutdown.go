@@ -0,0 +1,84 @@
+package ariabridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const gracefulPoll = 10 * time.Millisecond
+
+// CloseGracefully lets running control handlers finish, refusing new control
+// requests, then waits until buffered and queued events have been written and
+// acknowledged before closing the client. If ctx ends first the client is
+// closed anyway and the context error is returned with any close error.
+func (c *Client) CloseGracefully(ctx context.Context) error {
+	if err := c.waitControls(ctx); err != nil {
+		return errors.Join(fmt.Errorf("graceful close: %w", err), c.Close())
+	}
+	ticker := time.NewTicker(gracefulPoll)
+	defer ticker.Stop()
+	for !c.drained() {
+		select {
+		case <-ctx.Done():
+			return errors.Join(fmt.Errorf("graceful close: %w", ctx.Err()), c.Close())
+		case <-ticker.C:
+		}
+	}
+	return c.Close()
+}
+
+func (c *Client) drained() bool {
+	c.bufMu.Lock()
+	buffered := c.buffer.len()
+	c.bufMu.Unlock()
+	c.mu.Lock()
+	pending := len(c.pending)
+	c.mu.Unlock()
+	return buffered == 0 && pending == 0 && c.inflight.Load() == 0
+}
+
+// deadlineFlush writes out everything buffered, regardless of credits, when
+// the context passed to Start reaches its deadline. The writes get
+// writeDrainTimeout so a stalled server cannot hold up the return.
+func (c *Client) deadlineFlush(conn *websocket.Conn) {
+	conn.SetWriteDeadline(time.Now().Add(writeDrainTimeout))
+	c.bufMu.Lock()
+	if err := c.flushWith(false); err != nil {
+		c.log("deadline flush: " + err.Error())
+	}
+	c.unlockBuf()
+}
+
+// HandleSignals closes the client with CloseGracefully, allowing timeout, on
+// the first SIGINT or SIGTERM. It returns at once. The handlers are removed
+// when that signal arrives, so a second one gets the default behaviour, or
+// when ctx is done.
+func (c *Client) HandleSignals(ctx context.Context, timeout time.Duration) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		defer signal.Stop(sigs)
+		select {
+		case <-ctx.Done():
+		case sig := <-sigs:
+			signal.Stop(sigs)
+			c.closeOnSignal(sig, timeout)
+		}
+	}()
+}
+
+func (c *Client) closeOnSignal(sig os.Signal, timeout time.Duration) {
+	c.log("received " + sig.String() + ", closing")
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := c.CloseGracefully(ctx); err != nil {
+		c.log(err.Error())
+	}
+}
@@ -0,0 +1,129 @@
+package ariabridge
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestCloseGracefullyWaitsForBufferedEvents(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret"})
+	for i := 0; i < 5; i++ {
+		_ = c.SendConsole("info", "m"+itoa(i))
+	}
+	go c.Start(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := c.CloseGracefully(ctx); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	waitFor(t, func() bool { return len(h.ofType("console")) == 5 }, time.Second)
+}
+
+func TestCloseGracefullyGivesUpWithContext(t *testing.T) {
+	c := NewClient(ClientConfig{URL: "ws://127.0.0.1:1", Secret: "dev-secret"})
+	_ = c.SendConsole("info", "never delivered")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	if err := c.CloseGracefully(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+	if err := c.Start(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Fatalf("client not closed: %v", err)
+	}
+}
+
+func TestCloseGracefullyWaitsForControlHandlers(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret"})
+	started := make(chan struct{})
+	c.OnControl(func(msg map[string]any) (any, error) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		return "finished", nil
+	})
+	go c.Start(context.Background())
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	h.sendControlRequest(t, "slow", "work")
+	<-started
+	begin := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := c.CloseGracefully(ctx); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if time.Since(begin) < 80*time.Millisecond {
+		t.Fatalf("close did not wait for the handler")
+	}
+	waitFor(t, func() bool { return len(h.ofType("control_result")) == 1 }, time.Second)
+	if res := h.ofType("control_result")[0]; res["ok"] != true || res["result"] != "finished" {
+		t.Fatalf("result %v", res)
+	}
+
+	if c.beginControl() {
+		t.Fatalf("new control requests should be refused after shutdown begins")
+	}
+}
+
+func TestSignalTriggersGracefulClose(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret"})
+	for i := 0; i < 3; i++ {
+		_ = c.SendConsole("info", "m"+itoa(i))
+	}
+	done := make(chan error, 1)
+	go func() { done <- c.Start(context.Background()) }()
+
+	c.closeOnSignal(syscall.SIGTERM, time.Second)
+	waitFor(t, func() bool { return len(h.ofType("console")) == 3 }, time.Second)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("start still running after signal")
+	}
+	if err := c.Start(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Fatalf("client not closed: %v", err)
+	}
+}
+
+func TestFlushOnDeadlineWritesBufferedEvents(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	// without credits every event stays buffered until the final flush
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", FlowControl: true, FlushOnDeadline: true})
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- c.Start(ctx) }()
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+	for i := 0; i < 3; i++ {
+		_ = c.SendConsole("info", "m"+itoa(i))
+	}
+	if n := len(h.ofType("console")); n != 0 {
+		t.Fatalf("sent %d events without credits", n)
+	}
+
+	if err := <-done; !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("start: %v", err)
+	}
+	c.bufMu.Lock()
+	buffered := c.buffer.len()
+	c.bufMu.Unlock()
+	if buffered != 0 {
+		t.Fatalf("%d events left in the buffer", buffered)
+	}
+	waitFor(t, func() bool { return len(h.ofType("console")) == 3 }, time.Second)
+}
@@ -0,0 +1,22 @@
+package ariabridge
+
+import "time"
+
+// ClockSkew is how far the server clock runs ahead of the local clock, as of
+// the last auth_success or pong that carried a serverTime.
+func (c *Client) ClockSkew() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.skew
+}
+
+func (c *Client) observeServerTime(m map[string]any) {
+	ms, ok := m["serverTime"].(float64)
+	if !ok {
+		return
+	}
+	skew := time.UnixMilli(int64(ms)).Sub(time.Now())
+	c.mu.Lock()
+	c.skew = skew
+	c.mu.Unlock()
+}
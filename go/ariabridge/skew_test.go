@@ -0,0 +1,38 @@
+package ariabridge
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestClockSkewFromServerTime(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	h.onMessage(func(conn *websocket.Conn, m map[string]any) {
+		if m["type"] == "ping" {
+			_ = conn.WriteJSON(map[string]any{"type": "pong", "serverTime": time.Now().Add(90 * time.Second).UnixMilli()})
+		}
+	})
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", HeartbeatInterval: 20 * time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+
+	waitFor(t, func() bool { return c.ClockSkew() > time.Minute }, time.Second)
+	if skew := c.ClockSkew(); skew < 89*time.Second || skew > 91*time.Second {
+		t.Fatalf("skew %v", skew)
+	}
+}
+
+func TestClockSkewIgnoresMissingServerTime(t *testing.T) {
+	c := NewClient(ClientConfig{URL: "ws://0.0.0.0:1"})
+	c.observeServerTime(map[string]any{"type": "auth_success", "serverTime": float64(time.Now().Add(-time.Hour).UnixMilli())})
+	c.observeServerTime(map[string]any{"type": "pong"})
+	if skew := c.ClockSkew(); skew > -59*time.Minute || skew < -61*time.Minute {
+		t.Fatalf("skew %v", skew)
+	}
+}
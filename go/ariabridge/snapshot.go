@@ -0,0 +1,60 @@
+package ariabridge
+
+import "encoding/json"
+
+// Snapshot removes every buffered, unsent event and returns each encoded as
+// JSON, oldest first, so a replacement client can Restore them.
+func (c *Client) Snapshot() [][]byte {
+	c.bufMu.Lock()
+	pending := c.buffer.drain()
+	c.bufMu.Unlock()
+	c.checkPressure()
+	out := make([][]byte, 0, len(pending))
+	for _, q := range pending {
+		if q.raw != nil {
+			out = append(out, q.raw)
+			continue
+		}
+		data, err := c.cfg.Marshal(q.ev)
+		if err != nil {
+			c.log("snapshot: " + err.Error())
+			continue
+		}
+		out = append(out, data)
+	}
+	return out
+}
+
+// Restore puts events taken from another client's Snapshot ahead of anything
+// already buffered. They go through the same path as live sends, so a full
+// buffer applies Eviction and FallbackSink as usual; entries that are not
+// JSON objects are logged and skipped.
+func (c *Client) Restore(events [][]byte) {
+	if c.cfg.ReadOnly {
+		c.log("restore: " + ErrReadOnly.Error())
+		return
+	}
+	restored := make([]queued, 0, len(events))
+	for _, data := range events {
+		var ev map[string]any
+		if err := json.Unmarshal(data, &ev); err != nil {
+			c.log("restore: " + err.Error())
+			continue
+		}
+		// sequence numbers belong to the client that assigned them
+		delete(ev, "seq")
+		q := queued{ev: ev}
+		c.stampSeq(&q)
+		restored = append(restored, q)
+	}
+	c.bufMu.Lock()
+	existing := c.buffer.drain()
+	for _, q := range append(restored, existing...) {
+		if err := c.pushLocked(q); err != nil {
+			c.log("restore: " + err.Error())
+		}
+	}
+	c.unlockBuf()
+	c.checkPressure()
+	_ = c.flushBuffer()
+}
@@ -0,0 +1,54 @@
+package ariabridge
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRestoreMigratesBufferedEvents(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	old := NewClient(ClientConfig{URL: "ws://127.0.0.1:1", Secret: "dev-secret"})
+	_ = old.SendConsole("info", "first")
+	_ = old.SendRaw([]byte(`{"type":"console","level":"warn","message":"second"}`))
+	snap := old.Snapshot()
+	if len(snap) != 2 {
+		t.Fatalf("snapshot has %d events", len(snap))
+	}
+	if n, _ := old.CloseWithStats(); n != 0 {
+		t.Fatalf("old client still holds %d events", n)
+	}
+
+	next := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret"})
+	_ = next.SendConsole("info", "third")
+	next.Restore(snap)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go next.Start(ctx)
+
+	waitFor(t, func() bool { return len(h.ofType("console")) == 3 }, time.Second)
+	for i, want := range []string{"first", "second", "third"} {
+		if got := h.ofType("console")[i]["message"]; got != want {
+			t.Fatalf("event %d = %v, want %s", i, got, want)
+		}
+	}
+}
+
+func TestRestoreOverflowUsesEvictionAndSink(t *testing.T) {
+	rec := &sinkRecorder{}
+	c := NewClient(ClientConfig{URL: "ws://127.0.0.1:1", BufferLimit: 2, Eviction: EvictNewest, FallbackSink: rec.sink, FallbackDroppedEvents: true})
+	c.Restore([][]byte{
+		[]byte(`{"type":"console","level":"info","message":"a"}`),
+		[]byte(`{"type":"console","level":"info","message":"b"}`),
+		[]byte(`{"type":"console","level":"info","message":"c"}`),
+	})
+	if got := bufferedMessages(c); !reflect.DeepEqual([]any{"a", "b"}, got) {
+		t.Fatalf("buffered %v", got)
+	}
+	if got := rec.messages(); !reflect.DeepEqual([]any{"c"}, got) {
+		t.Fatalf("fallback received %v", got)
+	}
+}
@@ -0,0 +1,66 @@
+package ariabridge
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// SendError sends an error event carrying both the raw stack and its frames,
+// one per entry. With an empty stack the caller's stack is captured.
+func (c *Client) SendError(message, stack string) error {
+	var frames []string
+	if stack == "" {
+		frames = callerFrames(2)
+		stack = strings.Join(frames, "\n")
+	} else {
+		frames = stackFrames(stack)
+	}
+	return c.enqueue(map[string]any{
+		"type":      "error",
+		"message":   message,
+		"stack":     stack,
+		"frames":    frames,
+		"timestamp": time.Now().UnixMilli(),
+	})
+}
+
+// stackFrames splits a stack into frames. Go tracebacks, where each function
+// line is followed by an indented file:line, are folded into one frame per
+// call; other formats yield one frame per non-empty line.
+func stackFrames(stack string) []string {
+	var frames []string
+	for _, line := range strings.Split(stack, "\n") {
+		if strings.HasPrefix(line, "goroutine ") && strings.HasSuffix(line, ":") {
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "\t") && len(frames) > 0 {
+			if i := strings.LastIndex(trimmed, " +0x"); i > 0 {
+				trimmed = trimmed[:i]
+			}
+			frames[len(frames)-1] += " " + trimmed
+			continue
+		}
+		frames = append(frames, trimmed)
+	}
+	return frames
+}
+
+func callerFrames(skip int) []string {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(skip+1, pcs)
+	it := runtime.CallersFrames(pcs[:n])
+	var frames []string
+	for {
+		f, more := it.Next()
+		frames = append(frames, fmt.Sprintf("%s %s:%d", f.Function, f.File, f.Line))
+		if !more {
+			return frames
+		}
+	}
+}
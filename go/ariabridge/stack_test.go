@@ -0,0 +1,51 @@
+package ariabridge
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+const syntheticStack = `goroutine 1 [running]:
+main.handler(0xc000010000)
+	/app/main.go:42 +0x1d
+main.main()
+	/app/main.go:12 +0x25
+`
+
+func TestSendErrorStructuresStack(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	_ = c.SendError("boom", syntheticStack)
+	_ = c.SendError("captured", "")
+	waitFor(t, func() bool { return len(h.ofType("error")) == 2 }, time.Second)
+
+	ev := h.ofType("error")[0]
+	if ev["stack"] != syntheticStack {
+		t.Fatalf("raw stack %q", ev["stack"])
+	}
+	frames := ev["frames"].([]any)
+	if len(frames) != 2 || frames[0] != "main.handler(0xc000010000) /app/main.go:42" || frames[1] != "main.main() /app/main.go:12" {
+		t.Fatalf("frames %q", frames)
+	}
+
+	captured := h.ofType("error")[1]["frames"].([]any)
+	if len(captured) == 0 || !strings.Contains(captured[0].(string), "TestSendErrorStructuresStack") {
+		t.Fatalf("captured frames %q", captured)
+	}
+}
+
+func TestStackFramesPlainLines(t *testing.T) {
+	got := stackFrames("Error: bad\n    at f (a.js:1:2)\n\n    at g (b.js:3:4)\n")
+	if len(got) != 3 || got[1] != "at f (a.js:1:2)" {
+		t.Fatalf("frames %q", got)
+	}
+}
@@ -0,0 +1,78 @@
+package ariabridge
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	StateIdle         = "idle"
+	StateConnecting   = "connecting"
+	StateConnected    = "connected"
+	StateReconnecting = "reconnecting"
+	StateSuspended    = "suspended"
+	StateClosed       = "closed"
+)
+
+// OnStateChange registers a callback invoked on every connection state
+// transition, in order, from the goroutine running Start.
+func (c *Client) OnStateChange(handler func(old, new string)) {
+	c.mu.Lock()
+	c.stateHandler = handler
+	c.mu.Unlock()
+}
+
+func (c *Client) State() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state == "" {
+		return StateIdle
+	}
+	return c.state
+}
+
+// setState is the only place transitions are recorded and emitted.
+func (c *Client) setState(next string) {
+	c.mu.Lock()
+	old := c.state
+	if old == "" {
+		old = StateIdle
+	}
+	if old == next {
+		c.mu.Unlock()
+		return
+	}
+	c.accrueState(old, time.Now())
+	c.state = next
+	handler := c.stateHandler
+	c.mu.Unlock()
+	if handler != nil {
+		handler(old, next)
+	}
+}
+
+// Context is cancelled when the client is closed or its run in Start ends,
+// so work tied to the client's lifetime can stop with it.
+func (c *Client) Context() context.Context {
+	return c.life
+}
+
+// Ready is closed the first time the client has authenticated and sent
+// hello. Reconnects do not reopen it.
+func (c *Client) Ready() <-chan struct{} {
+	return c.ready
+}
+
+// accrueState adds the time since the last transition to the connected or
+// disconnected total. Idle, suspended and closed count as neither. Callers hold mu.
+func (c *Client) accrueState(state string, now time.Time) {
+	if !c.stateSince.IsZero() {
+		switch state {
+		case StateConnected:
+			c.upFor += now.Sub(c.stateSince)
+		case StateConnecting, StateReconnecting:
+			c.downFor += now.Sub(c.stateSince)
+		}
+	}
+	c.stateSince = now
+}
@@ -0,0 +1,136 @@
+package ariabridge
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStateTransitionsAcrossReconnect(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", BackoffInitial: 10 * time.Millisecond})
+	var mu sync.Mutex
+	var seen []string
+	c.OnStateChange(func(old, next string) {
+		mu.Lock()
+		seen = append(seen, old+">"+next)
+		mu.Unlock()
+	})
+	transitions := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), seen...)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = c.Start(ctx)
+		close(done)
+	}()
+
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+	h.mu.Lock()
+	_ = h.conn.Close()
+	h.mu.Unlock()
+	waitFor(t, func() bool { return len(transitions()) == 4 }, 2*time.Second)
+	cancel()
+	<-done
+
+	want := []string{
+		"idle>connecting",
+		"connecting>connected",
+		"connected>reconnecting",
+		"reconnecting>connected",
+		"connected>closed",
+	}
+	if got := transitions(); !reflect.DeepEqual(want, got) {
+		t.Fatalf("transitions %v", got)
+	}
+}
+
+func TestStartWithCancelledContextHasNoSideEffects(t *testing.T) {
+	c := NewClient(ClientConfig{URL: "ws://127.0.0.1:1", Secret: "dev-secret"})
+	var changes int
+	c.OnStateChange(func(string, string) { changes++ })
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	before := runtime.NumGoroutine()
+	if err := c.Start(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v", err)
+	}
+	if c.State() != StateIdle || changes != 0 {
+		t.Fatalf("state %s after %d changes", c.State(), changes)
+	}
+	waitFor(t, func() bool { return runtime.NumGoroutine() <= before }, time.Second)
+	c.mu.Lock()
+	started := c.started
+	c.mu.Unlock()
+	if started {
+		t.Fatalf("client marked started")
+	}
+}
+
+func TestContextCancelledOnClose(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret"})
+	life := c.Context()
+	go c.Start(context.Background())
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+	if life.Err() != nil {
+		t.Fatalf("context done while connected")
+	}
+	_ = c.Close()
+	select {
+	case <-life.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("context not cancelled by Close")
+	}
+}
+
+func TestContextCancelledWhenStartReturns(t *testing.T) {
+	c := NewClient(ClientConfig{URL: "ws://127.0.0.1:1", Secret: "dev-secret", FailFastOnFirstDial: true})
+	if err := c.Start(context.Background()); err == nil {
+		t.Fatalf("dial to a closed port succeeded")
+	}
+	if c.Context().Err() == nil {
+		t.Fatalf("context still live after Start returned")
+	}
+}
+
+func TestReadyClosesAfterFirstHandshake(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", BackoffInitial: 10 * time.Millisecond})
+	select {
+	case <-c.Ready():
+		t.Fatalf("ready before Start")
+	default:
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+
+	select {
+	case <-c.Ready():
+	case <-time.After(time.Second):
+		t.Fatalf("ready never closed")
+	}
+	if c.State() != StateConnected {
+		t.Fatalf("ready in state %s", c.State())
+	}
+	waitFor(t, func() bool { return len(h.ofType("hello")) == 1 }, time.Second)
+
+	h.mu.Lock()
+	_ = h.conn.Close()
+	h.mu.Unlock()
+	waitFor(t, func() bool { return len(h.ofType("hello")) == 2 }, 2*time.Second)
+}
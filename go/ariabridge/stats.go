@@ -0,0 +1,104 @@
+package ariabridge
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a snapshot of client counters.
+type Stats struct {
+	MalformedMessages uint64
+	Dropped           uint64
+	Reconnects        uint64
+	// Time spent connected, and connecting or reconnecting with events
+	// going to the buffer, including the current stretch.
+	ConnectedDuration    time.Duration
+	DisconnectedDuration time.Duration
+}
+
+type stats struct {
+	malformed atomic.Uint64
+	dropped   atomic.Uint64
+	connects  atomic.Uint64
+}
+
+func (c *Client) Stats() Stats {
+	c.bufMu.Lock()
+	dropped := c.stats.dropped.Load() + uint64(c.dropped)
+	c.bufMu.Unlock()
+	var reconnects uint64
+	if n := c.stats.connects.Load(); n > 1 {
+		reconnects = n - 1
+	}
+	c.mu.Lock()
+	c.accrueState(c.state, time.Now())
+	up, down := c.upFor, c.downFor
+	c.mu.Unlock()
+	return Stats{
+		MalformedMessages:    c.stats.malformed.Load(),
+		Dropped:              dropped,
+		Reconnects:           reconnects,
+		ConnectedDuration:    up,
+		DisconnectedDuration: down,
+	}
+}
+
+// statsLoop emits a bridge_stats event every StatsInterval while connected,
+// provided the bridge_stats capability was advertised.
+func (c *Client) statsLoop(ctx context.Context) {
+	ticker := time.NewTicker(c.cfg.StatsInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if c.State() != StateConnected || !c.hasCapability("bridge_stats") {
+				continue
+			}
+			if err := c.SendEvent(c.statsEvent()); err != nil {
+				c.log("bridge stats: " + err.Error())
+			}
+		}
+	}
+}
+
+func (c *Client) statsEvent() map[string]any {
+	st := c.Stats()
+	c.bufMu.Lock()
+	buffered := c.buffer.len()
+	c.bufMu.Unlock()
+	c.mu.Lock()
+	rtt := c.avgRTT
+	c.mu.Unlock()
+	return map[string]any{
+		"type":       "bridge_stats",
+		"buffered":   buffered,
+		"dropped":    st.Dropped,
+		"rttMs":      rtt.Milliseconds(),
+		"reconnects": st.Reconnects,
+		"timestamp":  time.Now().UnixMilli(),
+	}
+}
+
+// OnProtocolError registers a callback for frames from the server that could
+// not be decoded.
+func (c *Client) OnProtocolError(handler func(raw []byte, err error)) {
+	c.mu.Lock()
+	c.protocolError = handler
+	c.mu.Unlock()
+}
+
+// malformedFrame records an undecodable frame and reports whether
+// MaxMalformedFrames consecutive bad frames have now been seen.
+func (c *Client) malformedFrame(raw []byte, err error, consecutive int) bool {
+	c.stats.malformed.Add(1)
+	c.mu.Lock()
+	handler := c.protocolError
+	c.mu.Unlock()
+	if handler != nil {
+		handler(raw, err)
+	}
+	return c.cfg.MaxMalformedFrames > 0 && consecutive >= c.cfg.MaxMalformedFrames
+}
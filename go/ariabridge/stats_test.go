@@ -0,0 +1,143 @@
+package ariabridge
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func sendGarbage(t *testing.T, h *harness, frame string) {
+	t.Helper()
+	h.mu.Lock()
+	conn := h.conn
+	h.mu.Unlock()
+	_ = conn.WriteMessage(websocket.TextMessage, []byte(frame))
+}
+
+func TestMalformedFrameReported(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret"})
+	var mu sync.Mutex
+	var raws []string
+	c.OnProtocolError(func(raw []byte, err error) {
+		mu.Lock()
+		raws = append(raws, string(raw))
+		mu.Unlock()
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	sendGarbage(t, h, `{"type":"pong"`)
+	waitFor(t, func() bool { return c.Stats().MalformedMessages == 1 }, time.Second)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(raws) != 1 || raws[0] != `{"type":"pong"` {
+		t.Fatalf("callback got %q", raws)
+	}
+	if c.State() != StateConnected {
+		t.Fatalf("single bad frame should not reconnect")
+	}
+}
+
+func TestConsecutiveMalformedFramesReconnect(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", MaxMalformedFrames: 3, BackoffInitial: 5 * time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	sendGarbage(t, h, "x")
+	sendGarbage(t, h, "y")
+	h.sendJSON(t, map[string]any{"type": "noop"})
+	sendGarbage(t, h, "z")
+	time.Sleep(50 * time.Millisecond)
+	if n := len(h.ofType("auth")); n != 1 {
+		t.Fatalf("reconnected after non-consecutive bad frames")
+	}
+	sendGarbage(t, h, "1")
+	sendGarbage(t, h, "2")
+	waitFor(t, func() bool { return len(h.ofType("auth")) == 2 }, time.Second)
+}
+
+func TestStatsIntervalEmitsBridgeStats(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{
+		URL:           h.url,
+		Secret:        "dev-secret",
+		Capabilities:  []string{"console", "bridge_stats"},
+		StatsInterval: 30 * time.Millisecond,
+	})
+	go c.Start(context.Background())
+
+	waitFor(t, func() bool { return len(h.ofType("bridge_stats")) >= 1 }, 300*time.Millisecond)
+	ev := h.ofType("bridge_stats")[0]
+	for _, k := range []string{"buffered", "dropped", "rttMs", "reconnects", "timestamp"} {
+		if _, ok := ev[k]; !ok {
+			t.Fatalf("bridge_stats missing %s: %v", k, ev)
+		}
+	}
+
+	_ = c.Close()
+	n := len(h.ofType("bridge_stats"))
+	time.Sleep(100 * time.Millisecond)
+	if got := len(h.ofType("bridge_stats")); got != n {
+		t.Fatalf("stats emitted after Close: %d > %d", got, n)
+	}
+}
+
+func TestBridgeStatsRequiresCapability(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", StatsInterval: 10 * time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+
+	waitFor(t, func() bool { return len(h.ofType("hello")) == 1 }, time.Second)
+	time.Sleep(60 * time.Millisecond)
+	if n := len(h.ofType("bridge_stats")); n != 0 {
+		t.Fatalf("%d bridge_stats sent without capability", n)
+	}
+}
+
+func TestConnectedAndDisconnectedDurations(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", BackoffInitial: 150 * time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+	time.Sleep(20 * time.Millisecond)
+
+	h.mu.Lock()
+	_ = h.conn.Close()
+	h.mu.Unlock()
+	waitFor(t, func() bool { return c.State() == StateReconnecting }, time.Second)
+	waitFor(t, func() bool { return c.State() == StateConnected }, 2*time.Second)
+
+	st := c.Stats()
+	if st.DisconnectedDuration < 100*time.Millisecond {
+		t.Fatalf("disconnected for %v", st.DisconnectedDuration)
+	}
+	if st.ConnectedDuration < 20*time.Millisecond {
+		t.Fatalf("connected for %v", st.ConnectedDuration)
+	}
+	if later := c.Stats(); later.ConnectedDuration < st.ConnectedDuration || later.DisconnectedDuration != st.DisconnectedDuration {
+		t.Fatalf("durations moved: %+v then %+v", st, later)
+	}
+}
@@ -0,0 +1,67 @@
+package ariabridge
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StdoutWriter returns a writer that sends each line as an info console event
+// tagged with stream "stdout". Close sends any unterminated final line.
+func (c *Client) StdoutWriter() io.WriteCloser {
+	return &streamWriter{c: c, stream: "stdout", level: "info"}
+}
+
+// StderrWriter is like StdoutWriter but tags lines with stream "stderr" and
+// sends them at error level.
+func (c *Client) StderrWriter() io.WriteCloser {
+	return &streamWriter{c: c, stream: "stderr", level: "error"}
+}
+
+type streamWriter struct {
+	c      *Client
+	stream string
+	level  string
+	mu     sync.Mutex
+	buf    []byte
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := strings.TrimSuffix(string(w.buf[:i]), "\r")
+		w.buf = w.buf[i+1:]
+		if err := w.send(line); err != nil {
+			return len(p), err
+		}
+	}
+}
+
+func (w *streamWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) == 0 {
+		return nil
+	}
+	line := string(w.buf)
+	w.buf = nil
+	return w.send(line)
+}
+
+func (w *streamWriter) send(line string) error {
+	return w.c.enqueue(map[string]any{
+		"type":      "console",
+		"level":     w.level,
+		"message":   line,
+		"stream":    w.stream,
+		"timestamp": time.Now().UnixMilli(),
+	})
+}
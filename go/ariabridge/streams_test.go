@@ -0,0 +1,38 @@
+package ariabridge
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestStreamWritersTagAndLevel(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	out, errw := c.StdoutWriter(), c.StderrWriter()
+	fmt.Fprint(out, "hello ")
+	fmt.Fprint(out, "world\r\nsecond\n")
+	fmt.Fprint(errw, "failed\npartial")
+	_ = errw.Close()
+
+	waitFor(t, func() bool { return len(h.ofType("console")) == 4 }, time.Second)
+	want := []struct{ msg, stream, level string }{
+		{"hello world", "stdout", "info"},
+		{"second", "stdout", "info"},
+		{"failed", "stderr", "error"},
+		{"partial", "stderr", "error"},
+	}
+	for i, m := range h.ofType("console") {
+		if m["message"] != want[i].msg || m["stream"] != want[i].stream || m["level"] != want[i].level {
+			t.Fatalf("event %d: %v", i, m)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package ariabridge
+
+import "strings"
+
+// subscribedLevel reports whether console events at level may be sent. Levels
+// listed in OnDemandLevels only flow while the server has subscribed to them.
+func (c *Client) subscribedLevel(level string) bool {
+	level = strings.ToLower(level)
+	onDemand := false
+	for _, l := range c.cfg.OnDemandLevels {
+		if strings.ToLower(l) == level {
+			onDemand = true
+			break
+		}
+	}
+	if !onDemand {
+		return true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.subscribed[level]
+}
+
+func (c *Client) setSubscribed(m map[string]any, on bool) {
+	levels, _ := m["levels"].([]any)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.subscribed == nil {
+		c.subscribed = map[string]bool{}
+	}
+	for _, l := range levels {
+		if s, ok := l.(string); ok {
+			if on {
+				c.subscribed[strings.ToLower(s)] = true
+			} else {
+				delete(c.subscribed, strings.ToLower(s))
+			}
+		}
+	}
+}
+
+// resetSubscriptions forgets server subscriptions; a new connection has to
+// subscribe again.
+func (c *Client) resetSubscriptions() {
+	c.mu.Lock()
+	c.subscribed = nil
+	c.mu.Unlock()
+}
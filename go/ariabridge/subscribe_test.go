@@ -0,0 +1,39 @@
+package ariabridge
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestOnDemandLevelsFollowSubscription(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", OnDemandLevels: []string{"trace", "debug"}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	_ = c.SendConsole("debug", "unwatched")
+	h.sendJSON(t, map[string]any{"type": "subscribe", "levels": []string{"debug"}})
+	waitFor(t, func() bool { return c.subscribedLevel("debug") }, time.Second)
+	_ = c.SendConsole("debug", "watched")
+	_ = c.SendConsole("trace", "still unwatched")
+
+	h.sendJSON(t, map[string]any{"type": "unsubscribe", "levels": []string{"debug"}})
+	waitFor(t, func() bool { return !c.subscribedLevel("debug") }, time.Second)
+	_ = c.SendConsole("debug", "unwatched again")
+	_ = c.SendConsole("info", "always")
+
+	waitFor(t, func() bool { return len(h.ofType("console")) >= 2 }, time.Second)
+	time.Sleep(30 * time.Millisecond)
+	var got []any
+	for _, m := range h.ofType("console") {
+		got = append(got, m["message"])
+	}
+	if len(got) != 2 || got[0] != "watched" || got[1] != "always" {
+		t.Fatalf("console events %v", got)
+	}
+}
@@ -0,0 +1,75 @@
+package ariabridge
+
+import (
+	"os"
+	"sync"
+)
+
+const teeMaxBytesDefault = 10 << 20
+
+// teeFile appends events as JSON lines, rotating to path.1 once the file
+// would grow past max bytes.
+type teeFile struct {
+	mu   sync.Mutex
+	path string
+	max  int64
+	f    *os.File
+	size int64
+}
+
+func (t *teeFile) write(line []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.f != nil && t.size+int64(len(line))+1 > t.max {
+		_ = t.f.Close()
+		t.f = nil
+		if err := os.Rename(t.path, t.path+".1"); err != nil {
+			return err
+		}
+	}
+	if t.f == nil {
+		f, err := os.OpenFile(t.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return err
+		}
+		info, err := f.Stat()
+		if err != nil {
+			_ = f.Close()
+			return err
+		}
+		t.f, t.size = f, info.Size()
+	}
+	n, err := t.f.Write(append(line, '\n'))
+	t.size += int64(n)
+	return err
+}
+
+func (t *teeFile) close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.f == nil {
+		return nil
+	}
+	err := t.f.Close()
+	t.f = nil
+	return err
+}
+
+// teeEvent records q in TeeFile. Failures are logged and never reach the
+// sender.
+func (c *Client) teeEvent(q queued) {
+	if c.tee == nil {
+		return
+	}
+	line := q.raw
+	if line == nil {
+		var err error
+		if line, err = c.cfg.Marshal(q.ev); err != nil {
+			c.log("tee: " + err.Error())
+			return
+		}
+	}
+	if err := c.tee.write(line); err != nil {
+		c.log("tee: " + err.Error())
+	}
+}
@@ -0,0 +1,96 @@
+package ariabridge
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func teeLines(t *testing.T, path string) []map[string]any {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open tee: %v", err)
+	}
+	defer f.Close()
+	var out []map[string]any
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var m map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &m); err != nil {
+			t.Fatalf("tee line %q: %v", sc.Text(), err)
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+func TestTeeFileMirrorsEvents(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	path := filepath.Join(t.TempDir(), "bridge.jsonl")
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", TeeFile: path})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	_ = c.SendConsole("info", "one")
+	_ = c.SendRaw([]byte(`{"type":"console","level":"warn","message":"two"}`))
+	waitFor(t, func() bool { return len(h.ofType("console")) == 2 }, time.Second)
+	_ = c.Close()
+
+	lines := teeLines(t, path)
+	if len(lines) != 2 || lines[0]["message"] != "one" || lines[1]["message"] != "two" {
+		t.Fatalf("tee lines %v", lines)
+	}
+	if lines[0]["eventId"] != h.ofType("console")[0]["eventId"] {
+		t.Fatalf("tee and wire disagree: %v vs %v", lines[0], h.ofType("console")[0])
+	}
+}
+
+func TestTeeFileRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bridge.jsonl")
+	c := NewClient(ClientConfig{URL: "ws://127.0.0.1:1", TeeFile: path, TeeMaxBytes: 200})
+	for i := 0; i < 6; i++ {
+		_ = c.SendConsole("info", "message number "+itoa(i))
+	}
+	_ = c.Close()
+
+	current, rotated := teeLines(t, path), teeLines(t, path+".1")
+	if len(current) == 0 || len(rotated) == 0 || len(current)+len(rotated) > 6 {
+		t.Fatalf("current %d, rotated %d", len(current), len(rotated))
+	}
+	if last := current[len(current)-1]; last["message"] != "message number 5" {
+		t.Fatalf("last line %v", last)
+	}
+}
+
+func TestTeeFileErrorsDoNotBlockSends(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	var logged []string
+	c := NewClient(ClientConfig{
+		URL:     h.url,
+		Secret:  "dev-secret",
+		TeeFile: filepath.Join(t.TempDir(), "missing", "bridge.jsonl"),
+		Logger:  func(s string) { logged = append(logged, s) },
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	if err := c.SendConsole("info", "still sent"); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	waitFor(t, func() bool { return len(h.ofType("console")) == 1 }, time.Second)
+	if len(logged) == 0 {
+		t.Fatalf("tee failure was not logged")
+	}
+}
@@ -0,0 +1,107 @@
+package ariabridge
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func clientCertificate(t *testing.T, cn string) (tls.Certificate, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("certificate: %v", err)
+	}
+	leaf, _ := x509.ParseCertificate(der)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, leaf
+}
+
+func TestClientCertificatePresentedForMutualTLS(t *testing.T) {
+	cert, leaf := clientCertificate(t, "bridge-client")
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+
+	peers := make(chan string, 1)
+	up := websocket.Upgrader{}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := up.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		select {
+		case peers <- r.TLS.PeerCertificates[0].Subject.CommonName:
+		default:
+		}
+		for {
+			var m map[string]any
+			if err := conn.ReadJSON(&m); err != nil {
+				return
+			}
+			if m["type"] == "auth" {
+				_ = conn.WriteJSON(map[string]any{"type": "auth_success", "role": "bridge"})
+			}
+		}
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	c := NewClient(ClientConfig{
+		URL:                "wss" + srv.URL[5:],
+		Secret:             "dev-secret",
+		TLSConfig:          &tls.Config{RootCAs: roots},
+		ClientCertificates: []tls.Certificate{cert},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+
+	select {
+	case cn := <-peers:
+		if cn != "bridge-client" {
+			t.Fatalf("peer certificate %q", cn)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("mutual TLS handshake did not complete")
+	}
+}
+
+func TestTLSConfigNotMutatedByClientCertificates(t *testing.T) {
+	cert, _ := clientCertificate(t, "bridge-client")
+	base := &tls.Config{ServerName: "bridge"}
+	c := NewClient(ClientConfig{URL: "wss://bridge", TLSConfig: base, ClientCertificates: []tls.Certificate{cert}})
+	got := c.tlsConfig()
+	if got.ServerName != "bridge" || len(got.Certificates) != 1 {
+		t.Fatalf("tls config %+v", got)
+	}
+	if len(base.Certificates) != 0 {
+		t.Fatalf("base TLSConfig mutated")
+	}
+}
@@ -0,0 +1,24 @@
+package ariabridge
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrProtocolMismatch = errors.New("protocol version not supported by server")
+
+// checkProtocolRange validates ProtocolVersion against the optional
+// protocolRange {min, max} advertised in auth_success.
+func checkProtocolRange(m map[string]any) error {
+	r, ok := m["protocolRange"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	if lo, ok := r["min"].(float64); ok && ProtocolVersion < int(lo) {
+		return fmt.Errorf("%w: %d < min %d", ErrProtocolMismatch, ProtocolVersion, int(lo))
+	}
+	if hi, ok := r["max"].(float64); ok && ProtocolVersion > int(hi) {
+		return fmt.Errorf("%w: %d > max %d", ErrProtocolMismatch, ProtocolVersion, int(hi))
+	}
+	return nil
+}
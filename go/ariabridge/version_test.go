@@ -0,0 +1,40 @@
+package ariabridge
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestProtocolRangeMismatchStops(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	h.authInfo = map[string]any{"protocolRange": map[string]any{"min": ProtocolVersion + 1, "max": ProtocolVersion + 3}}
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret"})
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	err := c.Start(ctx)
+	if !errors.Is(err, ErrProtocolMismatch) {
+		t.Fatalf("expected ErrProtocolMismatch, got %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.msgs) != 1 || h.msgs[0]["type"] != "auth" {
+		t.Fatalf("expected only auth, got %v", h.msgs)
+	}
+}
+
+func TestProtocolRangeAccepted(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	h.authInfo = map[string]any{"protocolRange": map[string]any{"min": 1, "max": ProtocolVersion}}
+
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return len(h.ofType("hello")) == 1 }, time.Second)
+}
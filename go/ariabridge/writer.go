@@ -0,0 +1,241 @@
+package ariabridge
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrExpired = errors.New("delivery deadline passed")
+
+// errWriterStopped reports that the writer exited while a sender waited for
+// room; the event was put back in the buffer.
+var errWriterStopped = errors.New("writer stopped")
+
+// WriteOverflowPolicy decides what happens when WriteQueueSize is set and the
+// write queue is full.
+type WriteOverflowPolicy int
+
+const (
+	// WriteBlock makes senders wait for room in the queue. The wait happens
+	// with the buffer locked, to keep events in order, so while the socket
+	// is slow every Send, Stats and BufferPressure call waits with it. Use
+	// a drop policy where callers must not stall.
+	WriteBlock WriteOverflowPolicy = iota
+	// WriteDropOldest discards the oldest queued event to make room. It goes
+	// to FallbackSink when FallbackDroppedEvents is set.
+	WriteDropOldest
+	// WriteDropNewest discards the event being sent. It goes to
+	// FallbackSink when FallbackDroppedEvents is set.
+	WriteDropNewest
+	// WriteFallback hands the event being sent to FallbackSink.
+	WriteFallback
+)
+
+const writeDrainTimeout = time.Second
+
+// writeQueued performs the socket write for one event. An event past its
+// deadline is skipped and reported as ErrExpired.
+func (c *Client) writeQueued(q queued) error {
+	if q.expiredAt(time.Now()) {
+		c.expired(q)
+		return ErrExpired
+	}
+	var err error
+	if q.raw != nil {
+		err = c.writeRaw(q.raw)
+	} else {
+		written := c.chainSeq(q)
+		if err = c.send(q.ev); err == nil {
+			written()
+		}
+	}
+	for i := 0; err == nil && i < len(q.frames); i++ {
+		err = c.writeBinary(q.frames[i])
+	}
+	if err == nil {
+		c.lastSend.Store(int64(monoNow()))
+		c.trackAck(q)
+	}
+	return err
+}
+
+// SinceLastSend reports how long ago an event was last written to the socket,
+// or how long the client has existed if nothing has been written yet.
+func (c *Client) SinceLastSend() time.Duration {
+	return monoNow() - time.Duration(c.lastSend.Load())
+}
+
+func (c *Client) activeWriter() <-chan struct{} {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.writerDone
+}
+
+// queueWrite hands q to the writer goroutine, applying WriteOverflowPolicy
+// when the queue is full. Callers hold bufMu.
+func (c *Client) queueWrite(q queued, done <-chan struct{}) error {
+	c.inflight.Add(1)
+	select {
+	case c.writeQ <- q:
+		return nil
+	default:
+	}
+	switch c.cfg.WriteOverflowPolicy {
+	case WriteBlock:
+		select {
+		case c.writeQ <- q:
+			return nil
+		case <-done:
+			c.inflight.Add(-1)
+			c.requeue([]queued{q})
+			return errWriterStopped
+		}
+	case WriteDropOldest:
+		for {
+			select {
+			case c.writeQ <- q:
+				return nil
+			default:
+			}
+			select {
+			case old := <-c.writeQ:
+				c.inflight.Add(-1)
+				c.dropped++
+				c.fallbackDropped(old.ev)
+			default:
+			}
+		}
+	case WriteFallback:
+		c.inflight.Add(-1)
+		if c.cfg.FallbackSink != nil {
+			c.sinkQueue = append(c.sinkQueue, q.ev)
+			return nil
+		}
+		c.dropped++
+		return nil
+	default:
+		c.inflight.Add(-1)
+		c.dropped++
+		c.fallbackDropped(q.ev)
+		return nil
+	}
+}
+
+// startWriter runs the write queue for one connection until ctx is done. A
+// failed write tears the connection down and puts the event, with anything
+// queued behind it, back at the head of the buffer.
+func (c *Client) startWriter(ctx context.Context, cancel context.CancelFunc) <-chan struct{} {
+	exited := make(chan struct{})
+	c.writeMu.Lock()
+	c.writerDone = ctx.Done()
+	c.writeMu.Unlock()
+	go func() {
+		defer close(exited)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case q := <-c.writeQ:
+				err := c.writeQueued(q)
+				if errors.Is(err, ErrExpired) {
+					err = nil
+					if q.credit {
+						// the writer must not wait on its own queue
+						go c.flushBuffer()
+					}
+				}
+				if err != nil {
+					cancel()
+					c.bufMu.Lock()
+					c.requeue(append([]queued{q}, c.takeQueued()...))
+					c.unlockBuf()
+				}
+				c.inflight.Add(-1)
+				if err != nil {
+					return
+				}
+			}
+		}
+	}()
+	return exited
+}
+
+// stopWriter detaches the writer and moves anything still queued back into
+// the buffer so it is flushed on the next connection.
+func (c *Client) stopWriter(exited <-chan struct{}) {
+	<-exited
+	c.bufMu.Lock()
+	defer c.unlockBuf()
+	c.writeMu.Lock()
+	c.writerDone = nil
+	c.writeMu.Unlock()
+	c.requeue(c.takeQueued())
+}
+
+// takeQueued empties the write queue without blocking. The caller holds
+// bufMu.
+func (c *Client) takeQueued() []queued {
+	var qs []queued
+	for {
+		select {
+		case q := <-c.writeQ:
+			c.inflight.Add(-1)
+			qs = append(qs, q)
+		default:
+			return qs
+		}
+	}
+}
+
+// requeue puts events taken from the write queue, oldest first, back at the
+// head of the buffer. They were taken before anything still buffered, so
+// they go out first again. The caller holds bufMu.
+func (c *Client) requeue(qs []queued) {
+	for i := len(qs) - 1; i >= 0; i-- {
+		if c.buffer.pushFront(qs[i]) {
+			c.dropped++
+			c.fallbackDropped(qs[i].ev)
+		}
+	}
+}
+
+// waitWrites gives the writer a bounded chance to drain before Close.
+func (c *Client) waitWrites(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for c.inflight.Load() > 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// SendWithDeadline enqueues a copy of ev that is only worth delivering until
+// deadline. If it is still buffered or queued then, it is skipped when its
+// turn to be written comes and OnSendResult reports ErrExpired.
+func (c *Client) SendWithDeadline(ev map[string]any, deadline time.Time) error {
+	if t, _ := ev["type"].(string); t == "" {
+		return errors.New("event missing type")
+	}
+	return c.enqueueBy(copyEvent(ev), deadline)
+}
+
+func (q queued) expiredAt(now time.Time) bool {
+	return !q.deadline.IsZero() && now.After(q.deadline)
+}
+
+// expired reports q as skipped and returns the credit spent on it, since no
+// ack will come back for it.
+func (c *Client) expired(q queued) {
+	c.mu.Lock()
+	if q.credit {
+		c.credits++
+	}
+	if p, ok := c.pending[q.seq]; ok && q.seq != 0 {
+		p.timer.Stop()
+		delete(c.pending, q.seq)
+	}
+	handler := c.sendResult
+	c.mu.Unlock()
+	if handler != nil {
+		handler(q.ev, ErrExpired)
+	}
+}
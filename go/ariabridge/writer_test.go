@@ -0,0 +1,229 @@
+package ariabridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// slowWriterClient connects a client whose console writes take 20ms each,
+// so a burst of sends saturates a two-slot write queue.
+func slowWriterClient(t *testing.T, h *harness, policy WriteOverflowPolicy, sink func(map[string]any)) *Client {
+	t.Helper()
+	c := NewClient(ClientConfig{
+		URL:                   h.url,
+		Secret:                "dev-secret",
+		WriteQueueSize:        2,
+		WriteOverflowPolicy:   policy,
+		FallbackSink:          sink,
+		FallbackDroppedEvents: sink != nil,
+		Marshal: func(v any) ([]byte, error) {
+			if m, ok := v.(map[string]any); ok && m["type"] == "console" {
+				time.Sleep(20 * time.Millisecond)
+			}
+			return json.Marshal(v)
+		},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+	return c
+}
+
+func sendBurst(c *Client, n int) {
+	for i := 0; i < n; i++ {
+		_ = c.SendConsole("info", strconv.Itoa(i))
+	}
+}
+
+func consoleMessages(h *harness) map[string]bool {
+	got := map[string]bool{}
+	for _, m := range h.ofType("console") {
+		got[m["message"].(string)] = true
+	}
+	return got
+}
+
+func TestWriteQueueBlockDeliversEverything(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	c := slowWriterClient(t, h, WriteBlock, nil)
+
+	start := time.Now()
+	sendBurst(c, 10)
+	if time.Since(start) < 100*time.Millisecond {
+		t.Fatalf("senders were not blocked by a full queue")
+	}
+	waitFor(t, func() bool { return len(h.ofType("console")) == 10 }, 2*time.Second)
+	for i, m := range h.ofType("console") {
+		if m["message"] != strconv.Itoa(i) {
+			t.Fatalf("out of order at %d: %v", i, m["message"])
+		}
+	}
+}
+
+func TestWriteQueueDropNewest(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	c := slowWriterClient(t, h, WriteDropNewest, nil)
+
+	sendBurst(c, 10)
+	time.Sleep(200 * time.Millisecond)
+	got := consoleMessages(h)
+	if !got["0"] || !got["1"] || got["9"] || len(got) >= 10 {
+		t.Fatalf("drop-newest kept %v", got)
+	}
+}
+
+func TestWriteQueueDropOldest(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	var mu sync.Mutex
+	sunk := 0
+	c := slowWriterClient(t, h, WriteDropOldest, func(map[string]any) {
+		mu.Lock()
+		sunk++
+		mu.Unlock()
+	})
+
+	sendBurst(c, 10)
+	time.Sleep(200 * time.Millisecond)
+	got := consoleMessages(h)
+	if !got["8"] || !got["9"] || len(got) >= 10 {
+		t.Fatalf("drop-oldest kept %v", got)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got)+sunk != 10 {
+		t.Fatalf("sent %d, sunk %d", len(got), sunk)
+	}
+}
+
+func TestWriteQueueFallbackSink(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	var mu sync.Mutex
+	var sunk []map[string]any
+	var c *Client
+	c = slowWriterClient(t, h, WriteFallback, func(ev map[string]any) {
+		c.BufferPressure() // sinks run outside bufMu
+		mu.Lock()
+		sunk = append(sunk, ev)
+		mu.Unlock()
+	})
+
+	sendBurst(c, 10)
+	time.Sleep(200 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	sent := len(h.ofType("console"))
+	if len(sunk) == 0 || sent+len(sunk) != 10 {
+		t.Fatalf("sent %d, sunk %d", sent, len(sunk))
+	}
+	if sunk[len(sunk)-1]["message"] != "9" {
+		t.Fatalf("fallback got %v", sunk[len(sunk)-1])
+	}
+}
+
+func TestRequeuedEventsGoBeforeBuffered(t *testing.T) {
+	c := NewClient(ClientConfig{URL: "ws://0.0.0.0:1"})
+	c.buffer.push(queued{ev: map[string]any{"message": 3}})
+	c.bufMu.Lock()
+	c.requeue([]queued{{ev: map[string]any{"message": 1}}, {ev: map[string]any{"message": 2}}})
+	c.unlockBuf()
+	if got := ringMessages(c.buffer.drain()); !reflect.DeepEqual([]any{1, 2, 3}, got) {
+		t.Fatalf("buffer order %v", got)
+	}
+}
+
+func TestWriteQueueDrainsOnClose(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	c := slowWriterClient(t, h, WriteBlock, nil)
+
+	sendBurst(c, 4)
+	if err := c.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	waitFor(t, func() bool { return len(h.ofType("console")) == 4 }, time.Second)
+}
+
+func TestSinceLastSendResetsAndGrows(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	time.Sleep(60 * time.Millisecond)
+	idle := c.SinceLastSend()
+	if idle < 50*time.Millisecond {
+		t.Fatalf("idle duration %v", idle)
+	}
+	_ = c.SendConsole("info", "tick")
+	if d := c.SinceLastSend(); d >= idle || d > 20*time.Millisecond {
+		t.Fatalf("send did not reset: %v", d)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if d := c.SinceLastSend(); d < 30*time.Millisecond {
+		t.Fatalf("did not grow while idle: %v", d)
+	}
+}
+
+func TestSendWithDeadlineSkipsExpiredEvents(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	c := slowWriterClient(t, h, WriteBlock, nil)
+	var mu sync.Mutex
+	var expired []any
+	c.OnSendResult(func(ev map[string]any, err error) {
+		if errors.Is(err, ErrExpired) {
+			mu.Lock()
+			expired = append(expired, ev["message"])
+			mu.Unlock()
+		}
+	})
+
+	sendBurst(c, 3)
+	ev := map[string]any{"type": "console", "level": "info", "message": "late"}
+	_ = c.SendWithDeadline(ev, time.Now().Add(5*time.Millisecond))
+	_ = c.SendWithDeadline(map[string]any{"type": "console", "level": "info", "message": "patient"}, time.Now().Add(time.Minute))
+
+	waitFor(t, func() bool { return len(h.ofType("console")) == 4 }, 2*time.Second)
+	if got := consoleMessages(h); got["late"] || !got["patient"] {
+		t.Fatalf("delivered %v", got)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(expired) != 1 || expired[0] != "late" {
+		t.Fatalf("expired %v", expired)
+	}
+}
+
+func TestExpiredEventReturnsItsCredit(t *testing.T) {
+	h := newHarness(t, true)
+	defer h.close()
+	c := NewClient(ClientConfig{URL: h.url, Secret: "dev-secret", FlowControl: true})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Start(ctx)
+	waitFor(t, func() bool { return c.State() == StateConnected }, time.Second)
+
+	_ = c.SendWithDeadline(map[string]any{"type": "console", "level": "info", "message": "late"}, time.Now().Add(5*time.Millisecond))
+	_ = c.SendConsole("info", "live")
+	time.Sleep(20 * time.Millisecond)
+
+	h.sendJSON(t, map[string]any{"type": "credit", "n": 1})
+	waitFor(t, func() bool { return consoleMessages(h)["live"] }, time.Second)
+	if got := consoleMessages(h); got["late"] {
+		t.Fatalf("delivered %v", got)
+	}
+}
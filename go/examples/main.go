@@ -1,30 +1,66 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"io"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	cb "github.com/shaneholloman/aria-bridge/go/ariabridge"
 )
 
+const shutdownTimeout = 5 * time.Second
+
+// Usage: myapp | aria-bridge
 func main() {
 	url := getenv("ARIA_BRIDGE_URL", "ws://localhost:9877")
 	secret := getenv("ARIA_BRIDGE_SECRET", "dev-secret")
 
 	cfg := cb.ClientConfig{URL: url, Secret: secret, Capabilities: []string{"console", "error"}}
+	if err := Run(cfg, os.Stdin); err != nil {
+		log.Fatalf("bridge: %v", err)
+	}
+}
+
+// Run forwards each line read from stdin as an info console event until
+// stdin ends or SIGINT/SIGTERM arrives, then flushes and closes the client.
+func Run(cfg cb.ClientConfig, stdin io.Reader) error {
 	client := cb.NewClient(cfg)
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	defer client.Close()
+	sigCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	if err := client.Start(ctx); err != nil {
-		log.Fatalf("start failed: %v", err)
-	}
+	started := make(chan error, 1)
+	go func() { started <- client.Start(context.Background()) }()
+
+	lines := make(chan error, 1)
+	go func() {
+		sc := bufio.NewScanner(stdin)
+		for sc.Scan() {
+			if err := client.SendConsole("info", sc.Text()); err != nil {
+				log.Printf("bridge: send: %v", err)
+			}
+		}
+		lines <- sc.Err()
+	}()
 
-	_ = client.SendConsole("info", "hello from go example")
-	time.Sleep(500 * time.Millisecond)
-	_ = client.Close()
+	var err error
+	select {
+	case err = <-lines:
+	case <-sigCtx.Done():
+	case err = <-started:
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if cerr := client.CloseGracefully(ctx); cerr != nil && err == nil {
+		err = cerr
+	}
+	return err
 }
 
 func getenv(k, def string) string {
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	cb "github.com/shaneholloman/aria-bridge/go/ariabridge"
+	"github.com/shaneholloman/aria-bridge/go/ariabridge/ariabridgetest"
+)
+
+func TestRunForwardsLinesAndFlushesOnEOF(t *testing.T) {
+	srv := ariabridgetest.NewServer()
+	defer srv.Close()
+
+	cfg := cb.ClientConfig{URL: srv.URL, Secret: "dev-secret"}
+	if err := Run(cfg, strings.NewReader("first\nsecond\nthird\n")); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	// the client is already closed, so anything that arrives was flushed
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	got, err := srv.WaitFor(ctx, "console", 3)
+	if err != nil {
+		t.Fatalf("expected 3 console events after shutdown, got %d", len(srv.MessagesOfType("console")))
+	}
+	for i, want := range []string{"first", "second", "third"} {
+		if got[i]["message"] != want || got[i]["level"] != "info" {
+			t.Fatalf("event %d: %v", i, got[i])
+		}
+	}
+}